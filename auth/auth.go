@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"strings"
 
@@ -8,6 +10,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// ActorKey is the context key under which AuthMiddleware stores the
+// identity of the caller
+const ActorKey = "actor"
+
+// TokenIdentity returns a stable, non-secret name for a token: a short
+// SHA-256 fingerprint that can be written to logs
+func TokenIdentity(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])[:12]
+}
+
 // AuthMiddleware checks for valid Bearer token in Authorization header
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -32,6 +45,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		c.Set(ActorKey, TokenIdentity(token))
 		c.Next()
 	}
 }
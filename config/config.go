@@ -6,14 +6,31 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
+	Backend            string
 	DhcpConfPath       string
+	Dhcp6ConfPath      string
 	InterfacesConfPath string
+	KeaConfPath        string
+	KeaControlURL      string
+	DnsmasqConfPath    string
+	LeasesPath         string
+	CheckCommand       string
+	Check6Command      string
+	ApplyCommand       string
+	ApplyMode          string
+	ApplyDebounce      time.Duration
+	HistoryDir         string
+	HistoryRetention   int
+	AuditLogPath       string
+	AllocationExclude  string
 	TokenSecret        string
 	TokenFilePath      string
 	Environment        string
@@ -22,6 +39,20 @@ type Config struct {
 
 var AppConfig Config
 
+// backendDefaults holds the settings whose defaults depend on the DHCP
+// server being managed
+var backendDefaults = map[string]struct {
+	leasesPath   string
+	checkCommand string
+	applyCommand string
+}{
+	"isc":     {"/var/lib/dhcp/dhcpd.leases", "dhcpd -t -cf {file}", "systemctl restart isc-dhcp-server"},
+	"kea":     {"/var/lib/kea/kea-leases4.csv", "kea-dhcp4 -t {file}", "systemctl reload kea-dhcp4-server"},
+	"dnsmasq": {"/var/lib/misc/dnsmasq.leases", "dnsmasq --test --conf-file={file}", "systemctl restart dnsmasq"},
+	// Checked and reloaded through the control API instead
+	"kea-ctrl": {"/var/lib/kea/kea-leases4.csv", "", ""},
+}
+
 func LoadConfig() {
 	// Only load .env file in development
 	env := getEnv("ENVIRONMENT", "development")
@@ -30,8 +61,27 @@ func LoadConfig() {
 	}
 
 	AppConfig.Environment = env
+	AppConfig.Backend = getEnv("BACKEND", "isc")
+	defaults, ok := backendDefaults[AppConfig.Backend]
+	if !ok {
+		log.Fatalf("Invalid BACKEND %q: must be isc, kea, kea-ctrl or dnsmasq", AppConfig.Backend)
+	}
 	AppConfig.DhcpConfPath = getEnv("DHCP_CONF_PATH", "/etc/dhcp/dhcpd.conf")
+	AppConfig.Dhcp6ConfPath = getEnv("DHCP6_CONF_PATH", "/etc/dhcp/dhcpd6.conf")
 	AppConfig.InterfacesConfPath = getEnv("INTERFACES_CONF_PATH", "/etc/default/isc-dhcp-server")
+	AppConfig.KeaConfPath = getEnv("KEA_CONF_PATH", "/etc/kea/kea-dhcp4.conf")
+	AppConfig.KeaControlURL = getEnv("KEA_CONTROL_URL", "http://127.0.0.1:8000/")
+	AppConfig.DnsmasqConfPath = getEnv("DNSMASQ_CONF_PATH", "/etc/dnsmasq.d/dhcp-rest-api.conf")
+	AppConfig.LeasesPath = getEnv("LEASES_PATH", defaults.leasesPath)
+	AppConfig.CheckCommand = getEnv("CHECK_COMMAND", defaults.checkCommand)
+	AppConfig.Check6Command = getEnv("CHECK6_COMMAND", "dhcpd -6 -t -cf {file}")
+	AppConfig.ApplyCommand = getEnv("APPLY_COMMAND", defaults.applyCommand)
+	AppConfig.ApplyMode = getEnv("APPLY_MODE", "immediate")
+	AppConfig.ApplyDebounce = getDurationEnv("APPLY_DEBOUNCE", 5*time.Second)
+	AppConfig.HistoryDir = getEnv("HISTORY_DIR", "/var/lib/dhcp-rest-api/history")
+	AppConfig.HistoryRetention = getIntEnv("HISTORY_RETENTION", 50)
+	AppConfig.AuditLogPath = getEnv("AUDIT_LOG_PATH", "/var/log/dhcp-rest-api/audit.log")
+	AppConfig.AllocationExclude = getEnv("ALLOCATION_EXCLUDE", "")
 	AppConfig.TokenFilePath = getEnv("TOKEN_FILE_PATH", "/etc/dhcp-rest-api/token")
 	AppConfig.Port = getEnv("PORT", "8080")
 
@@ -68,6 +118,12 @@ func LoadConfig() {
 		log.Println("You can also retrieve it later from the token file or change it via the API.")
 	}
 
+	switch AppConfig.ApplyMode {
+	case "immediate", "debounce", "manual":
+	default:
+		log.Fatalf("Invalid APPLY_MODE %q: must be immediate, debounce or manual", AppConfig.ApplyMode)
+	}
+
 	if AppConfig.Environment == "production" {
 		validateProductionConfig()
 	}
@@ -75,8 +131,19 @@ func LoadConfig() {
 	if AppConfig.Environment == "development" {
 		log.Println("Configuration loaded successfully")
 		log.Printf("Environment: %s", AppConfig.Environment)
+		log.Printf("BACKEND: %s", AppConfig.Backend)
 		log.Printf("DHCP_CONF_PATH: %s", AppConfig.DhcpConfPath)
+		log.Printf("DHCP6_CONF_PATH: %s", AppConfig.Dhcp6ConfPath)
 		log.Printf("INTERFACES_CONF_PATH: %s", AppConfig.InterfacesConfPath)
+		log.Printf("KEA_CONF_PATH: %s", AppConfig.KeaConfPath)
+		log.Printf("KEA_CONTROL_URL: %s", AppConfig.KeaControlURL)
+		log.Printf("DNSMASQ_CONF_PATH: %s", AppConfig.DnsmasqConfPath)
+		log.Printf("LEASES_PATH: %s", AppConfig.LeasesPath)
+		log.Printf("CHECK_COMMAND: %s", AppConfig.CheckCommand)
+		log.Printf("APPLY_COMMAND: %s", AppConfig.ApplyCommand)
+		log.Printf("APPLY_MODE: %s", AppConfig.ApplyMode)
+		log.Printf("HISTORY_DIR: %s", AppConfig.HistoryDir)
+		log.Printf("AUDIT_LOG_PATH: %s", AppConfig.AuditLogPath)
 		log.Printf("TOKEN_FILE_PATH: %s", AppConfig.TokenFilePath)
 		log.Printf("PORT: %s", AppConfig.Port)
 	}
@@ -88,12 +155,27 @@ func validateProductionConfig() {
 	}
 
 	// Check if config files exist
-	if _, err := os.Stat(AppConfig.DhcpConfPath); os.IsNotExist(err) {
-		log.Printf("Warning: DHCP config path does not exist: %s", AppConfig.DhcpConfPath)
-	}
+	switch AppConfig.Backend {
+	case "kea", "kea-ctrl":
+		if _, err := os.Stat(AppConfig.KeaConfPath); os.IsNotExist(err) {
+			log.Printf("Warning: Kea config path does not exist: %s", AppConfig.KeaConfPath)
+		}
+	case "dnsmasq":
+		if _, err := os.Stat(AppConfig.DnsmasqConfPath); os.IsNotExist(err) {
+			log.Printf("Warning: dnsmasq config path does not exist: %s", AppConfig.DnsmasqConfPath)
+		}
+	default:
+		if _, err := os.Stat(AppConfig.DhcpConfPath); os.IsNotExist(err) {
+			log.Printf("Warning: DHCP config path does not exist: %s", AppConfig.DhcpConfPath)
+		}
+
+		if _, err := os.Stat(AppConfig.Dhcp6ConfPath); os.IsNotExist(err) {
+			log.Printf("Warning: DHCPv6 config path does not exist: %s", AppConfig.Dhcp6ConfPath)
+		}
 
-	if _, err := os.Stat(AppConfig.InterfacesConfPath); os.IsNotExist(err) {
-		log.Printf("Warning: Interfaces config path does not exist: %s", AppConfig.InterfacesConfPath)
+		if _, err := os.Stat(AppConfig.InterfacesConfPath); os.IsNotExist(err) {
+			log.Printf("Warning: Interfaces config path does not exist: %s", AppConfig.InterfacesConfPath)
+		}
 	}
 
 	log.Printf("Production configuration validated successfully")
@@ -119,6 +201,32 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid duration %q for %s, using %s", value, key, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getIntEnv(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Warning: invalid number %q for %s, using %d", value, key, fallback)
+		return fallback
+	}
+	return n
+}
+
 func loadTokenFromFile() string {
 	if content, err := os.ReadFile(AppConfig.TokenFilePath); err == nil {
 		return strings.TrimSpace(string(content))
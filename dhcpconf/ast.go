@@ -0,0 +1,300 @@
+package dhcpconf
+
+import (
+	"slices"
+	"strings"
+)
+
+// Node is an element of the syntax tree: a *Comment, *Statement or *Block
+type Node interface {
+	Pos() Position
+	node()
+}
+
+// Comment is a `#` comment running to the end of the line
+type Comment struct {
+	Position
+	Space  string // whitespace preceding the comment
+	Text   string // including the leading '#'
+	parsed bool
+}
+
+// Statement is a simple statement terminated by ';', e.g.
+// `option routers 10.0.0.1;`. Args holds the source text of each token.
+type Statement struct {
+	Position
+	Space       string
+	Args        []string
+	LineComment *Comment // comment on the same line after the ';'
+	raw         string
+	orig        []string
+	parsed      bool
+}
+
+// Block is a declaration with a braced body, e.g. `host web01 { ... }`
+type Block struct {
+	Position
+	Space       string
+	Args        []string
+	Body        []Node
+	Close       string   // whitespace preceding the closing brace
+	LineComment *Comment // comment on the same line after the '}'
+	raw         string
+	orig        []string
+	parsed      bool
+}
+
+// File is a parsed configuration file
+type File struct {
+	Body     []Node
+	Trailing string // whitespace after the last node
+}
+
+func (c *Comment) Pos() Position   { return c.Position }
+func (s *Statement) Pos() Position { return s.Position }
+func (b *Block) Pos() Position     { return b.Position }
+
+func (*Comment) node()   {}
+func (*Statement) node() {}
+func (*Block) node()     {}
+
+// NewComment returns a comment node; a leading '#' is added if missing
+func NewComment(text string) *Comment {
+	if !strings.HasPrefix(text, "#") {
+		text = "# " + text
+	}
+	return &Comment{Text: text}
+}
+
+// NewStatement returns a statement built from the given argument tokens
+func NewStatement(args ...string) *Statement {
+	return &Statement{Args: args}
+}
+
+// NewBlock returns an empty block with the given header tokens
+func NewBlock(args ...string) *Block {
+	return &Block{Args: args}
+}
+
+// Keyword returns the first token of the statement
+func (s *Statement) Keyword() string {
+	if len(s.Args) == 0 {
+		return ""
+	}
+	return s.Args[0]
+}
+
+// Keyword returns the first token of the block header
+func (b *Block) Keyword() string {
+	if len(b.Args) == 0 {
+		return ""
+	}
+	return b.Args[0]
+}
+
+// Name returns the unquoted second header token, which is the declared name
+// for host, group, shared-network and class blocks
+func (b *Block) Name() string {
+	if len(b.Args) < 2 {
+		return ""
+	}
+	return Unquote(b.Args[1])
+}
+
+// Match reports whether the statement starts with the given tokens and
+// returns the remaining arguments
+func (s *Statement) Match(prefix ...string) ([]string, bool) {
+	if len(s.Args) < len(prefix) {
+		return nil, false
+	}
+	for i, p := range prefix {
+		if s.Args[i] != p {
+			return nil, false
+		}
+	}
+	return s.Args[len(prefix):], true
+}
+
+// Find returns the first direct child statement starting with prefix
+func (b *Block) Find(prefix ...string) *Statement {
+	return find(b.Body, prefix)
+}
+
+// Find returns the first top-level statement starting with prefix
+func (f *File) Find(prefix ...string) *Statement {
+	return find(f.Body, prefix)
+}
+
+func find(nodes []Node, prefix []string) *Statement {
+	for _, n := range nodes {
+		if s, ok := n.(*Statement); ok {
+			if _, ok := s.Match(prefix...); ok {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// Blocks returns the direct child blocks with the given keyword
+func (b *Block) Blocks(keyword string) []*Block {
+	return blocks(b.Body, keyword)
+}
+
+// Blocks returns the top-level blocks with the given keyword
+func (f *File) Blocks(keyword string) []*Block {
+	return blocks(f.Body, keyword)
+}
+
+func blocks(nodes []Node, keyword string) []*Block {
+	var out []*Block
+	for _, n := range nodes {
+		if b, ok := n.(*Block); ok && b.Keyword() == keyword {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// Append adds nodes to the end of the block body
+func (b *Block) Append(nodes ...Node) {
+	b.Body = append(b.Body, nodes...)
+}
+
+// Append adds nodes to the end of the file
+func (f *File) Append(nodes ...Node) {
+	f.Body = append(f.Body, nodes...)
+}
+
+// InsertAfter adds n right after the direct child ref, or at the end of the
+// file if ref is nil or not found
+func (f *File) InsertAfter(ref, n Node) {
+	insertAfter(&f.Body, ref, n)
+}
+
+// InsertAfter adds n right after the direct child ref, or at the end of the
+// block if ref is nil or not found
+func (b *Block) InsertAfter(ref, n Node) {
+	insertAfter(&b.Body, ref, n)
+}
+
+// Prepend adds n at the start of the file
+func (f *File) Prepend(n Node) {
+	f.Body = slices.Insert(f.Body, 0, n)
+}
+
+func insertAfter(nodes *[]Node, ref, n Node) {
+	for i, existing := range *nodes {
+		if ref != nil && existing == ref {
+			*nodes = slices.Insert(*nodes, i+1, n)
+			return
+		}
+	}
+	*nodes = append(*nodes, n)
+}
+
+// Remove deletes target from the file, searching nested blocks
+func (f *File) Remove(target Node) bool {
+	return remove(&f.Body, target)
+}
+
+// Remove deletes target from the block body, searching nested blocks
+func (b *Block) Remove(target Node) bool {
+	return remove(&b.Body, target)
+}
+
+func remove(nodes *[]Node, target Node) bool {
+	for i, n := range *nodes {
+		if n == target {
+			*nodes = slices.Delete(*nodes, i, i+1)
+			return true
+		}
+		if b, ok := n.(*Block); ok && remove(&b.Body, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace swaps old for new in place, searching nested blocks. The new node
+// takes over the leading whitespace of the old one.
+func (f *File) Replace(old, new Node) bool {
+	return replace(f.Body, old, new)
+}
+
+func replace(nodes []Node, old, new Node) bool {
+	for i, n := range nodes {
+		if n == old {
+			oldSpace, oldParsed := leading(old)
+			newSpace, newParsed := leading(new)
+			*newSpace, *newParsed = *oldSpace, *oldParsed
+			nodes[i] = new
+			return true
+		}
+		if b, ok := n.(*Block); ok && replace(b.Body, old, new) {
+			return true
+		}
+	}
+	return false
+}
+
+// leading exposes the whitespace bookkeeping shared by all node types
+func leading(n Node) (space *string, parsed *bool) {
+	switch n := n.(type) {
+	case *Comment:
+		return &n.Space, &n.parsed
+	case *Statement:
+		return &n.Space, &n.parsed
+	case *Block:
+		return &n.Space, &n.parsed
+	}
+	return new(string), new(bool)
+}
+
+// Reset discards the original formatting of n and everything inside it, so
+// the printer lays it out afresh. Use it after moving a node to a different
+// nesting level.
+func Reset(n Node) {
+	switch n := n.(type) {
+	case *Comment:
+		n.Space, n.parsed = "", false
+	case *Statement:
+		n.Space, n.raw, n.parsed = "", "", false
+	case *Block:
+		n.Space, n.raw, n.Close, n.parsed = "", "", "", false
+		for _, child := range n.Body {
+			Reset(child)
+		}
+	}
+}
+
+// Walk visits nodes depth-first. fn receives each node together with the
+// chain of enclosing blocks, outermost first; returning false skips the
+// children of a block.
+func Walk(nodes []Node, fn func(n Node, parents []*Block) bool) {
+	walk(nodes, nil, fn)
+}
+
+func walk(nodes []Node, parents []*Block, fn func(Node, []*Block) bool) {
+	for _, n := range nodes {
+		if !fn(n, parents) {
+			continue
+		}
+		if b, ok := n.(*Block); ok {
+			walk(b.Body, append(parents[:len(parents):len(parents)], b), fn)
+		}
+	}
+}
+
+// Join renders argument tokens as they would appear in a statement,
+// without a space before commas
+func Join(args []string) string {
+	var b strings.Builder
+	for i, a := range args {
+		if i > 0 && a != "," {
+			b.WriteByte(' ')
+		}
+		b.WriteString(a)
+	}
+	return b.String()
+}
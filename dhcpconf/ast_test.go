@@ -0,0 +1,214 @@
+package dhcpconf
+
+import (
+	"testing"
+)
+
+const editSrc = `# dhcpd.conf
+option domain-name "example.org";   # keep this spacing
+
+group {
+	option routers 10.0.0.1;
+	host web01 { hardware ethernet 00:11:22:33:44:55; fixed-address 10.0.0.5; } # web
+	host db01 {
+		fixed-address 10.0.0.6;
+	}
+}
+
+host mail01 {
+	fixed-address 10.0.0.7; # mail
+}
+`
+
+func findBlock(f *File, keyword, name string) *Block {
+	var found *Block
+	Walk(f.Body, func(n Node, _ []*Block) bool {
+		if b, ok := n.(*Block); ok && found == nil && b.Keyword() == keyword && b.Name() == name {
+			found = b
+		}
+		return found == nil
+	})
+	return found
+}
+
+func TestEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(t *testing.T, f *File)
+		want string
+	}{
+		{
+			name: "no edit",
+			edit: func(*testing.T, *File) {},
+			want: editSrc,
+		},
+		{
+			name: "change one statement",
+			edit: func(t *testing.T, f *File) {
+				findBlock(f, "host", "db01").Find("fixed-address").Args[1] = "10.0.0.60"
+			},
+			want: `# dhcpd.conf
+option domain-name "example.org";   # keep this spacing
+
+group {
+	option routers 10.0.0.1;
+	host web01 { hardware ethernet 00:11:22:33:44:55; fixed-address 10.0.0.5; } # web
+	host db01 {
+		fixed-address 10.0.0.60;
+	}
+}
+
+host mail01 {
+	fixed-address 10.0.0.7; # mail
+}
+`,
+		},
+		{
+			name: "append statement uses file indent",
+			edit: func(t *testing.T, f *File) {
+				findBlock(f, "host", "db01").Append(NewStatement("hardware", "ethernet", "00:11:22:33:44:66"))
+			},
+			want: `# dhcpd.conf
+option domain-name "example.org";   # keep this spacing
+
+group {
+	option routers 10.0.0.1;
+	host web01 { hardware ethernet 00:11:22:33:44:55; fixed-address 10.0.0.5; } # web
+	host db01 {
+		fixed-address 10.0.0.6;
+		hardware ethernet 00:11:22:33:44:66;
+	}
+}
+
+host mail01 {
+	fixed-address 10.0.0.7; # mail
+}
+`,
+		},
+		{
+			name: "remove host takes its line comment",
+			edit: func(t *testing.T, f *File) {
+				if !f.Remove(findBlock(f, "host", "web01")) {
+					t.Fatal("web01 not removed")
+				}
+			},
+			want: `# dhcpd.conf
+option domain-name "example.org";   # keep this spacing
+
+group {
+	option routers 10.0.0.1;
+	host db01 {
+		fixed-address 10.0.0.6;
+	}
+}
+
+host mail01 {
+	fixed-address 10.0.0.7; # mail
+}
+`,
+		},
+		{
+			name: "remove statement takes its line comment",
+			edit: func(t *testing.T, f *File) {
+				mail := findBlock(f, "host", "mail01")
+				mail.Remove(mail.Find("fixed-address"))
+				mail.Append(NewStatement("fixed-address", "10.0.0.8"))
+			},
+			want: `# dhcpd.conf
+option domain-name "example.org";   # keep this spacing
+
+group {
+	option routers 10.0.0.1;
+	host web01 { hardware ethernet 00:11:22:33:44:55; fixed-address 10.0.0.5; } # web
+	host db01 {
+		fixed-address 10.0.0.6;
+	}
+}
+
+host mail01 {
+	fixed-address 10.0.0.8;
+}
+`,
+		},
+		{
+			name: "move host out of group keeps its line comment",
+			edit: func(t *testing.T, f *File) {
+				web := findBlock(f, "host", "web01")
+				f.Remove(web)
+				Reset(web)
+				f.Append(web)
+			},
+			want: `# dhcpd.conf
+option domain-name "example.org";   # keep this spacing
+
+group {
+	option routers 10.0.0.1;
+	host db01 {
+		fixed-address 10.0.0.6;
+	}
+}
+
+host mail01 {
+	fixed-address 10.0.0.7; # mail
+}
+
+host web01 {
+	hardware ethernet 00:11:22:33:44:55;
+	fixed-address 10.0.0.5;
+} # web
+`,
+		},
+		{
+			name: "insert after and prepend",
+			edit: func(t *testing.T, f *File) {
+				f.InsertAfter(f.Find("option", "domain-name"), NewStatement("option", "domain-name-servers", "10.0.0.1"))
+				f.Prepend(NewComment("managed"))
+			},
+			want: `# managed
+# dhcpd.conf
+option domain-name "example.org";   # keep this spacing
+option domain-name-servers 10.0.0.1;
+
+group {
+	option routers 10.0.0.1;
+	host web01 { hardware ethernet 00:11:22:33:44:55; fixed-address 10.0.0.5; } # web
+	host db01 {
+		fixed-address 10.0.0.6;
+	}
+}
+
+host mail01 {
+	fixed-address 10.0.0.7; # mail
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse([]byte(editSrc))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			tt.edit(t, f)
+			if got := string(f.Bytes()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if _, err := Parse(f.Bytes()); err != nil {
+				t.Errorf("edited output does not parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewFileIndent(t *testing.T) {
+	f := &File{}
+	host := NewBlock("host", "web01")
+	host.Append(NewStatement("fixed-address", "10.0.0.5"))
+	group := NewBlock("group")
+	group.Append(host)
+	f.Append(NewStatement("authoritative"), NewComment("hosts"), group)
+	want := "authoritative;\n# hosts\ngroup {\n    host web01 {\n        fixed-address 10.0.0.5;\n    }\n}\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
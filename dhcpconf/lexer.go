@@ -0,0 +1,273 @@
+package dhcpconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position identifies a location in the source text
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// TokenKind classifies a lexical token
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenWord
+	TokenString
+	TokenComment
+	TokenSemicolon
+	TokenLBrace
+	TokenRBrace
+	TokenComma
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenEOF:
+		return "end of file"
+	case TokenWord:
+		return "word"
+	case TokenString:
+		return "string"
+	case TokenComment:
+		return "comment"
+	case TokenSemicolon:
+		return "';'"
+	case TokenLBrace:
+		return "'{'"
+	case TokenRBrace:
+		return "'}'"
+	case TokenComma:
+		return "','"
+	}
+	return "unknown token"
+}
+
+// Token is a single lexical element. Text is the exact source text, so
+// strings keep their quotes and escapes.
+type Token struct {
+	Kind  TokenKind
+	Text  string
+	Pos   Position
+	Space string // whitespace preceding the token
+}
+
+// Error is a lexing or parsing error tied to a source position
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+type lexer struct {
+	src  string
+	off  int
+	line int
+	col  int
+}
+
+// Lex splits dhcpd.conf source into tokens. Whitespace is attached to the
+// token that follows it; whitespace at the end of the input is attached to
+// the EOF token.
+func Lex(src string) ([]Token, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+	var tokens []Token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Kind == TokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) pos() Position {
+	return Position{Offset: l.off, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) advance() {
+	if l.src[l.off] == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	l.off++
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDelimiter(c byte) bool {
+	return isSpace(c) || strings.IndexByte(`;{},"#`, c) >= 0
+}
+
+func (l *lexer) next() (Token, error) {
+	start := l.off
+	for l.off < len(l.src) && isSpace(l.src[l.off]) {
+		l.advance()
+	}
+	tok := Token{Space: l.src[start:l.off], Pos: l.pos()}
+	if l.off >= len(l.src) {
+		tok.Kind = TokenEOF
+		return tok, nil
+	}
+
+	begin := l.off
+	switch c := l.src[l.off]; c {
+	case ';':
+		tok.Kind = TokenSemicolon
+		l.advance()
+	case '{':
+		tok.Kind = TokenLBrace
+		l.advance()
+	case '}':
+		tok.Kind = TokenRBrace
+		l.advance()
+	case ',':
+		tok.Kind = TokenComma
+		l.advance()
+	case '#':
+		tok.Kind = TokenComment
+		for l.off < len(l.src) && l.src[l.off] != '\n' {
+			l.advance()
+		}
+	case '"':
+		tok.Kind = TokenString
+		l.advance()
+		for {
+			if l.off >= len(l.src) || l.src[l.off] == '\n' {
+				return Token{}, &Error{Pos: tok.Pos, Msg: "unterminated string"}
+			}
+			c := l.src[l.off]
+			l.advance()
+			if c == '"' {
+				break
+			}
+			if c == '\\' && l.off < len(l.src) {
+				l.advance()
+			}
+		}
+	default:
+		tok.Kind = TokenWord
+		for l.off < len(l.src) && !isDelimiter(l.src[l.off]) {
+			l.advance()
+		}
+	}
+	tok.Text = l.src[begin:l.off]
+	return tok, nil
+}
+
+// Quote returns s as a dhcpd string literal
+func Quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\%03o`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Unquote strips the quotes and escapes from a string token. Anything that
+// is not a quoted string is returned unchanged.
+func Unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			if s[i] >= '0' && s[i] <= '7' && i+2 < len(s) {
+				// dhcpd writes non-printable bytes as three-digit octal escapes
+				v := 0
+				j := i
+				for ; j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+					v = v*8 + int(s[j]-'0')
+				}
+				if j == i+3 {
+					b.WriteByte(byte(v))
+					i = j - 1
+					continue
+				}
+			}
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// Fields splits a parameter value such as `8.8.8.8, 8.8.4.4` into argument
+// tokens. Only words, strings and commas are allowed, so a value can never
+// terminate its statement or open a block. Raw control characters are
+// rejected as well; Quote escapes them in strings.
+func Fields(value string) ([]string, error) {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; (c < 0x20 && c != ' ' && c != '\t') || c == 0x7f {
+			return nil, &Error{Pos: Position{Offset: i, Line: 1, Column: i + 1}, Msg: fmt.Sprintf("control character %q in value", c)}
+		}
+	}
+	tokens, err := Lex(value)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, tok := range tokens {
+		switch tok.Kind {
+		case TokenEOF:
+			return args, nil
+		case TokenWord, TokenString, TokenComma:
+			args = append(args, tok.Text)
+		default:
+			return nil, &Error{Pos: tok.Pos, Msg: fmt.Sprintf("unexpected %s in value", tok.Kind)}
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,143 @@
+package dhcpconf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type parser struct {
+	src    string
+	tokens []Token
+	pos    int
+}
+
+// Parse builds a syntax tree from dhcpd.conf (or dhcpd.leases) source
+func Parse(src []byte) (*File, error) {
+	tokens, err := Lex(string(src))
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: string(src), tokens: tokens}
+	body, end, err := p.parseBody(false)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Body: body, Trailing: end.Space}, nil
+}
+
+// ParseFile reads and parses the file at path
+func ParseFile(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", path, err)
+	}
+	return f, nil
+}
+
+func (p *parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != TokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseBody collects nodes until EOF (top level) or a closing brace (inside
+// a block) and returns the token that ended the body
+func (p *parser) parseBody(inBlock bool) ([]Node, Token, error) {
+	var nodes []Node
+	for {
+		tok := p.next()
+		switch tok.Kind {
+		case TokenEOF:
+			if inBlock {
+				return nil, tok, &Error{Pos: tok.Pos, Msg: "unexpected end of file, missing '}'"}
+			}
+			return nodes, tok, nil
+		case TokenRBrace:
+			if !inBlock {
+				return nil, tok, &Error{Pos: tok.Pos, Msg: "unexpected '}'"}
+			}
+			return nodes, tok, nil
+		case TokenComment:
+			nodes = append(nodes, &Comment{Position: tok.Pos, Space: tok.Space, Text: tok.Text, parsed: true})
+		default:
+			n, err := p.parseDeclaration(tok)
+			if err != nil {
+				return nil, tok, err
+			}
+			p.lineComment(n)
+			nodes = append(nodes, n)
+		}
+	}
+}
+
+// lineComment attaches a comment that follows n on the same line, so that
+// it stays with n when the node is removed or moved
+func (p *parser) lineComment(n Node) {
+	tok := p.tokens[p.pos]
+	if tok.Kind != TokenComment || strings.Contains(tok.Space, "\n") {
+		return
+	}
+	p.pos++
+	c := &Comment{Position: tok.Pos, Space: tok.Space, Text: tok.Text, parsed: true}
+	switch n := n.(type) {
+	case *Statement:
+		n.LineComment = c
+	case *Block:
+		n.LineComment = c
+	}
+}
+
+// parseDeclaration reads a statement or block starting at first
+func (p *parser) parseDeclaration(first Token) (Node, error) {
+	var args []string
+	tok := first
+	for {
+		switch tok.Kind {
+		case TokenWord, TokenString, TokenComma:
+			args = append(args, tok.Text)
+		case TokenComment:
+			// a comment inside a declaration stays part of its raw text
+		case TokenSemicolon:
+			return &Statement{
+				Position: first.Pos,
+				Space:    first.Space,
+				Args:     args,
+				raw:      p.src[first.Pos.Offset : tok.Pos.Offset+1],
+				orig:     clone(args),
+				parsed:   true,
+			}, nil
+		case TokenLBrace:
+			b := &Block{
+				Position: first.Pos,
+				Space:    first.Space,
+				Args:     args,
+				raw:      p.src[first.Pos.Offset : tok.Pos.Offset+1],
+				orig:     clone(args),
+				parsed:   true,
+			}
+			body, end, err := p.parseBody(true)
+			if err != nil {
+				return nil, err
+			}
+			b.Body = body
+			b.Close = end.Space
+			return b, nil
+		case TokenRBrace:
+			return nil, &Error{Pos: tok.Pos, Msg: "unexpected '}', missing ';'"}
+		case TokenEOF:
+			return nil, &Error{Pos: tok.Pos, Msg: "unexpected end of file, missing ';'"}
+		}
+		tok = p.next()
+	}
+}
+
+func clone(args []string) []string {
+	return append([]string(nil), args...)
+}
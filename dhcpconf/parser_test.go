@@ -0,0 +1,144 @@
+package dhcpconf
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"empty", ""},
+		{"no trailing newline", "option domain-name \"example.org\";"},
+		{"comment with braces", "# host old { hardware ethernet 00:11:22:33:44:55; }\nddns-update-style none;\n"},
+		{"comment inside block with braces", "host web01 {\n  # } stray brace\n  fixed-address 10.0.0.5; # { another\n}\n"},
+		{"quoted strings", "option domain-name \"a;b {c} # d\";\nfilename \"pxe\\\"linux\\\\0\";\n"},
+		{"comment inside statement", "option domain-name-servers 8.8.8.8, # primary\n    8.8.4.4;\n"},
+		{"tabs and crlf", "subnet 10.0.0.0 netmask 255.255.255.0 {\r\n\trange 10.0.0.10 10.0.0.20;\r\n}\r\n"},
+		{"nested blocks", `shared-network lan {
+    option domain-name "lan";
+    subnet 10.0.0.0 netmask 255.255.255.0 {
+        range 10.0.0.10 10.0.0.99;
+        pool {
+            range 10.0.0.100 10.0.0.150;
+        }
+    }
+    group {
+        host a { hardware ethernet 00:11:22:33:44:55; fixed-address 10.0.0.5; } # first
+        host b {
+            if exists user-class and option user-class = "iPXE" {
+                filename "boot.ipxe";
+            } else {
+                filename "undionly.kpxe";
+            }
+        }
+    }
+}
+
+
+# trailing comment
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got := string(f.Bytes())
+			want := tt.src
+			if want != "" && want[len(want)-1] != '\n' {
+				want += "\n"
+			}
+			if got != want {
+				t.Errorf("round trip mismatch\ngot:\n%q\nwant:\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"missing close", "host a {\n  fixed-address 10.0.0.5;\n", "3:1: unexpected end of file, missing '}'"},
+		{"stray close", "}\n", "1:1: unexpected '}'"},
+		{"missing semicolon", "host a { fixed-address 10.0.0.5 }", "1:33: unexpected '}', missing ';'"},
+		{"unterminated string", "option domain-name \"lan;\n", "1:20: unterminated string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.src))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	src := `shared-network "lan" {
+    subnet 10.0.0.0 netmask 255.255.255.0 {
+        group { host web01 { fixed-address 10.0.0.5; } }
+    }
+}
+`
+	f, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var hosts []string
+	Walk(f.Body, func(n Node, parents []*Block) bool {
+		if b, ok := n.(*Block); ok && b.Keyword() == "host" {
+			var chain []string
+			for _, p := range parents {
+				chain = append(chain, p.Keyword())
+			}
+			if !slices.Equal(chain, []string{"shared-network", "subnet", "group"}) {
+				t.Errorf("parents of %s = %v", b.Name(), chain)
+			}
+			hosts = append(hosts, b.Name())
+		}
+		return true
+	})
+	if !slices.Equal(hosts, []string{"web01"}) {
+		t.Errorf("hosts = %v, want [web01]", hosts)
+	}
+	if name := f.Blocks("shared-network")[0].Name(); name != "lan" {
+		t.Errorf("shared-network name = %q, want unquoted lan", name)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []string{"", "plain", `a"b`, `c:\boot`, "tab\there", "line\nbreak", "bell\a"}
+	for _, s := range tests {
+		if got := Unquote(Quote(s)); got != s {
+			t.Errorf("Unquote(Quote(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"8.8.8.8, 8.8.4.4", []string{"8.8.8.8", ",", "8.8.4.4"}, false},
+		{`"a b"`, []string{`"a b"`}, false},
+		{"10.0.0.1; deny booting", nil, true},
+		{"x { y", nil, true},
+		{"x # y", nil, true},
+		{"a\nb", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := Fields(tt.value)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("Fields(%q) = %q, %v", tt.value, got, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package dhcpconf
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+const defaultIndent = "    "
+
+// Bytes renders the file. Nodes that came from the parser and were not
+// modified are written back exactly as they were read; new or edited nodes
+// are formatted to match the indentation of their neighbours.
+func (f *File) Bytes() []byte {
+	var buf bytes.Buffer
+	p := &printer{buf: &buf, unit: indentUnit(f.Body)}
+	p.printNodes(f.Body, "", true)
+	buf.WriteString(f.Trailing)
+	if f.Trailing == "" && buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func (f *File) String() string {
+	return string(f.Bytes())
+}
+
+type printer struct {
+	buf  *bytes.Buffer
+	unit string
+}
+
+// indentUnit returns the indentation step used by the first nested node in
+// the file, so that new nodes blend in with hand-written ones
+func indentUnit(nodes []Node) string {
+	for _, n := range nodes {
+		b, ok := n.(*Block)
+		if !ok || !b.parsed {
+			continue
+		}
+		outer, ok := lineIndent(b)
+		if !ok && b.Space != "" {
+			continue
+		}
+		for _, child := range b.Body {
+			if inner, ok := lineIndent(child); ok && len(inner) > len(outer) && strings.HasPrefix(inner, outer) {
+				return inner[len(outer):]
+			}
+		}
+	}
+	return defaultIndent
+}
+
+func (p *printer) printNodes(nodes []Node, indent string, topLevel bool) {
+	buf := p.buf
+	for i, n := range nodes {
+		space, parsed := leading(n)
+		switch {
+		case *parsed && *space == "" && buf.Len() > 0:
+			// originally the first node of the file, now preceded by a new one
+			if _, ok := n.(*Block); ok {
+				buf.WriteString("\n\n")
+			} else {
+				buf.WriteString("\n")
+			}
+		case *parsed:
+			buf.WriteString(*space)
+		case buf.Len() == 0:
+		case isElse(n) && i > 0 && isBlock(nodes[i-1]):
+			buf.WriteString(" ")
+		case topLevel:
+			// a new comment right before a block heads it
+			if _, ok := n.(*Block); ok && !isNewComment(nodes, i-1) {
+				buf.WriteString("\n\n")
+			} else {
+				buf.WriteString("\n")
+			}
+		default:
+			buf.WriteString("\n" + siblingIndent(nodes, i, indent))
+		}
+		p.printNode(n, siblingIndent(nodes, i, indent))
+		p.printLineComment(n)
+	}
+}
+
+func isNewComment(nodes []Node, i int) bool {
+	if i < 0 {
+		return false
+	}
+	c, ok := nodes[i].(*Comment)
+	return ok && !c.parsed
+}
+
+func (p *printer) printLineComment(n Node) {
+	var c *Comment
+	switch n := n.(type) {
+	case *Statement:
+		c = n.LineComment
+	case *Block:
+		c = n.LineComment
+	}
+	if c == nil {
+		return
+	}
+	if c.parsed {
+		p.buf.WriteString(c.Space)
+	} else {
+		p.buf.WriteByte(' ')
+	}
+	p.buf.WriteString(c.Text)
+}
+
+func isBlock(n Node) bool {
+	_, ok := n.(*Block)
+	return ok
+}
+
+// isElse reports whether n continues a preceding if block
+func isElse(n Node) bool {
+	b, ok := n.(*Block)
+	return ok && (b.Keyword() == "else" || b.Keyword() == "elsif")
+}
+
+// siblingIndent guesses the indentation for nodes[i] from the closest parsed
+// sibling that starts on its own line, falling back to def
+func siblingIndent(nodes []Node, i int, def string) string {
+	for j := i; j >= 0; j-- {
+		if ind, ok := lineIndent(nodes[j]); ok {
+			return ind
+		}
+	}
+	for j := i + 1; j < len(nodes); j++ {
+		if ind, ok := lineIndent(nodes[j]); ok {
+			return ind
+		}
+	}
+	return def
+}
+
+func lineIndent(n Node) (string, bool) {
+	space, parsed := leading(n)
+	if !*parsed {
+		return "", false
+	}
+	nl := strings.LastIndexByte(*space, '\n')
+	if nl < 0 {
+		return "", false
+	}
+	return (*space)[nl+1:], true
+}
+
+func (p *printer) printNode(n Node, indent string) {
+	buf := p.buf
+	switch n := n.(type) {
+	case *Comment:
+		buf.WriteString(n.Text)
+	case *Statement:
+		if n.raw != "" && slices.Equal(n.Args, n.orig) {
+			buf.WriteString(n.raw)
+			return
+		}
+		buf.WriteString(Join(n.Args))
+		buf.WriteByte(';')
+	case *Block:
+		if n.raw != "" && slices.Equal(n.Args, n.orig) {
+			buf.WriteString(n.raw)
+		} else {
+			buf.WriteString(Join(n.Args))
+			buf.WriteString(" {")
+		}
+		p.printNodes(n.Body, indent+p.unit, false)
+		if n.parsed {
+			buf.WriteString(n.Close)
+		} else {
+			buf.WriteString("\n" + indent)
+		}
+		buf.WriteByte('}')
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAudit returns audit log entries, optionally filtered by time range
+// (RFC 3339 `since`/`until`) and actor
+func ListAudit(c *gin.Context) {
+	var filter models.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+		return
+	}
+
+	entries, err := services.ListAudit(filter)
+	if err != nil {
+		log.Printf("Error reading audit log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audit log: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// auditHost records the state of a host, as returned by the backend call
+// that changed it, for the audit log under key. A zero host, e.g. from the
+// delete of a host that did not exist, is left out.
+func auditHost(c *gin.Context, key string, host models.Host) {
+	if host.Name != "" {
+		c.Set(key, host)
+	}
+}
+
+// auditInterfaces records the current interface lists for the audit log
+func auditInterfaces(c *gin.Context, key string) {
+	if interfaces, err := services.CurrentBackend().GetInterfaces(); err == nil {
+		c.Set(key, interfaces)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/middleware"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ApplyBatch applies a list of host and interface operations
+// all-or-nothing, with a single config rewrite and reload
+func ApplyBatch(c *gin.Context) {
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	results, err := services.ApplyBatch(req.Operations)
+	if err != nil {
+		log.Printf("Error applying batch of %d operations: %v", len(req.Operations), err)
+		message := "Batch failed, no changes were made: " + err.Error()
+		var batchErr *services.BatchError
+		if errors.As(err, &batchErr) {
+			message = fmt.Sprintf("Batch failed at operation %d, no changes were made: %v", batchErr.Index, batchErr.Err)
+		}
+		respondError(c, http.StatusBadRequest, message, err)
+		return
+	}
+	c.Set(middleware.AuditAfterKey, results)
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("%d operations applied successfully", len(results)), "results": results, "apply": services.NotifyChanged()})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// precondition reads the If-Match and If-None-Match headers of a write
+func precondition(c *gin.Context) services.Precondition {
+	return services.Precondition{
+		IfMatch:     c.GetHeader("If-Match"),
+		IfNoneMatch: c.GetHeader("If-None-Match"),
+	}
+}
+
+// notModified sets the ETag header of a read and answers 304 when the
+// client already holds that version. It reports whether the response has
+// been written.
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		if tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/"); tag == etag || tag == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// respondError reports a failed service call. Configurations rejected by
+// the syntax checker are answered with 422 and the checker's diagnostics,
+// invalid fields with 400 naming the field, conflicting hosts with 409 and
+// the existing host, failed preconditions with 412 and missing objects
+// with 404, and a Kea update that lost the old reservation with 500;
+// anything else uses status, with the reason Kea gave if it refused a
+// command. err is attached to the context so the request logger and audit
+// log can see it.
+func respondError(c *gin.Context, status int, message string, err error) {
+	_ = c.Error(err)
+	// withDetail appends the specific reason unless message already has it
+	withDetail := func(detail string) string {
+		if strings.Contains(message, detail) {
+			return message
+		}
+		return strings.TrimSuffix(message, ".") + ": " + detail
+	}
+	var restoreErr *services.KeaRestoreError
+	if errors.As(err, &restoreErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": withDetail(restoreErr.Error())})
+		return
+	}
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": message, "details": validationErr.Lines})
+		return
+	}
+	var fieldErr *services.FieldError
+	if errors.As(err, &fieldErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": withDetail(fieldErr.Msg), "field": fieldErr.Field})
+		return
+	}
+	var conflictErr *services.ConflictError
+	if errors.As(err, &conflictErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": withDetail(conflictErr.Error()), "field": conflictErr.Field, "conflict": conflictErr.Host})
+		return
+	}
+	if errors.Is(err, services.ErrPreconditionFailed) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": withDetail("the resource has changed since it was read")})
+		return
+	}
+	if errors.Is(err, services.ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	var keaErr *services.KeaCommandError
+	if errors.As(err, &keaErr) {
+		message = withDetail(keaErr.Text)
+	}
+	c.JSON(status, gin.H{"error": message})
+}
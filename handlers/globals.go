@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+func GetGlobals(c *gin.Context) {
+	globals, err := services.GetGlobals()
+	if err != nil {
+		log.Printf("Error reading global parameters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read global parameters"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"globals": globals})
+}
+
+func UpdateGlobals(c *gin.Context) {
+	var globalsUpdate models.GlobalsUpdate
+	if err := c.ShouldBindJSON(&globalsUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.UpdateGlobals(globalsUpdate); err != nil {
+		log.Printf("Error updating global parameters: %v", err)
+		respondError(c, http.StatusBadRequest, "Failed to update global parameters: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Global parameters updated successfully", "apply": services.NotifyChanged()})
+}
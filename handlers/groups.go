@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ListGroups(c *gin.Context) {
+	groups, err := services.ListGroups()
+	if err != nil {
+		log.Printf("Error listing groups: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list groups"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+func AddGroup(c *gin.Context) {
+	var group models.Group
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.AddGroup(group); err != nil {
+		log.Printf("Error adding group %s: %v", group.Name, err)
+		respondError(c, http.StatusBadRequest, "Failed to add group: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Group added successfully", "apply": services.NotifyChanged()})
+}
+
+func UpdateGroup(c *gin.Context) {
+	name := c.Param("name")
+	var groupUpdate models.GroupUpdate
+
+	if err := c.ShouldBindJSON(&groupUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.UpdateGroup(name, groupUpdate); err != nil {
+		log.Printf("Error updating group %s: %v", name, err)
+		respondError(c, http.StatusBadRequest, "Failed to update group: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Group updated successfully", "apply": services.NotifyChanged()})
+}
+
+func DeleteGroup(c *gin.Context) {
+	name := c.Param("name")
+
+	deleted, err := services.DeleteGroup(name)
+	if err != nil {
+		log.Printf("Error deleting group %s: %v", name, err)
+		respondError(c, http.StatusBadRequest, "Failed to delete group: "+err.Error(), err)
+		return
+	}
+	respondWritten(c, "Group deleted successfully", deleted, services.NotifyChanged)
+}
+
+func ListSharedNetworks(c *gin.Context) {
+	networks, err := services.ListSharedNetworks()
+	if err != nil {
+		log.Printf("Error listing shared networks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list shared networks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"shared_networks": networks})
+}
+
+func AddSharedNetwork(c *gin.Context) {
+	var shared models.SharedNetwork
+	if err := c.ShouldBindJSON(&shared); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.AddSharedNetwork(shared); err != nil {
+		log.Printf("Error adding shared network %s: %v", shared.Name, err)
+		respondError(c, http.StatusBadRequest, "Failed to add shared network: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shared network added successfully", "apply": services.NotifyChanged()})
+}
+
+func UpdateSharedNetwork(c *gin.Context) {
+	name := c.Param("name")
+	var sharedUpdate models.SharedNetworkUpdate
+
+	if err := c.ShouldBindJSON(&sharedUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.UpdateSharedNetwork(name, sharedUpdate); err != nil {
+		log.Printf("Error updating shared network %s: %v", name, err)
+		respondError(c, http.StatusBadRequest, "Failed to update shared network: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shared network updated successfully", "apply": services.NotifyChanged()})
+}
+
+func DeleteSharedNetwork(c *gin.Context) {
+	name := c.Param("name")
+
+	deleted, err := services.DeleteSharedNetwork(name)
+	if err != nil {
+		log.Printf("Error deleting shared network %s: %v", name, err)
+		respondError(c, http.StatusBadRequest, "Failed to delete shared network: "+err.Error(), err)
+		return
+	}
+	respondWritten(c, "Shared network deleted successfully", deleted, services.NotifyChanged)
+}
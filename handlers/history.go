@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListHistory lists config snapshots, newest first. ?file= limits the list
+// to dhcpd.conf, dhcpd6.conf or interfaces.
+func ListHistory(c *gin.Context) {
+	entries, err := services.ListHistory(c.Query("file"))
+	if err != nil {
+		log.Printf("Error listing history: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list history: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"history": entries})
+}
+
+// GetHistory returns a snapshot and its diff against the previous version
+func GetHistory(c *gin.Context) {
+	detail, err := services.GetHistory(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to get snapshot: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// RestoreHistory writes a snapshot back over the live file
+func RestoreHistory(c *gin.Context) {
+	id := c.Param("id")
+	if err := services.RestoreHistory(id); err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to restore snapshot: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Snapshot " + id + " restored successfully", "apply": services.CurrentBackend().NotifyChanged()})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/middleware"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// transferFormat picks csv or json from the format query parameter,
+// falling back to the request's Content-Type and then JSON
+func transferFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	if strings.Contains(c.ContentType(), "csv") {
+		return "csv"
+	}
+	return "json"
+}
+
+// ExportHosts downloads every host reservation as CSV or JSON
+func ExportHosts(c *gin.Context) {
+	format := transferFormat(c)
+	data, err := services.ExportHosts(format)
+	if err != nil {
+		log.Printf("Error exporting hosts as %s: %v", format, err)
+		respondError(c, http.StatusInternalServerError, "Failed to export hosts", err)
+		return
+	}
+	contentType := "application/json; charset=utf-8"
+	if format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="hosts.%s"`, format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ImportHosts adds host reservations from a CSV or JSON upload. Rows are
+// reported individually and nothing is written unless every row imports.
+func ImportHosts(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: dry_run must be a boolean"})
+		return
+	}
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	format := transferFormat(c)
+	report, err := services.ImportHosts(format, data, c.DefaultQuery("on_conflict", "fail"), dryRun)
+	if err != nil {
+		log.Printf("Error importing hosts: %v", err)
+		var importErr *services.ImportError
+		if errors.As(err, &importErr) {
+			_ = c.Error(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Import failed, no changes were made: " + err.Error(), "report": report})
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Failed to import hosts", err)
+		return
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"message": "Dry run, no changes were made", "report": report})
+		return
+	}
+	c.Set(middleware.AuditAfterKey, report)
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("%d hosts imported successfully", report.Added+report.Overwritten), "report": report, "apply": services.NotifyChanged()})
+}
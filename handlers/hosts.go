@@ -4,19 +4,55 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/0xPixelNinja/dhcp-rest-api/middleware"
 	"github.com/0xPixelNinja/dhcp-rest-api/models"
 	"github.com/0xPixelNinja/dhcp-rest-api/services"
 	"github.com/gin-gonic/gin"
 )
 
+// ListHosts lists host reservations, filtered, sorted and paginated by the
+// query string. An exact lookup by mac or ip that matches nothing is a 404,
+// so clients can test whether a reservation exists; any other listing
+// answers 200, with an empty list if nothing matches.
 func ListHosts(c *gin.Context) {
-	hosts, err := services.ListHosts()
+	var query models.HostQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+		return
+	}
+
+	hosts, total, err := services.CurrentBackend().ListHosts(query)
 	if err != nil {
 		log.Printf("Error listing hosts: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list hosts"})
+		respondError(c, http.StatusInternalServerError, "Failed to list hosts", err)
+		return
+	}
+	if total == 0 && (query.MAC != "" || query.IP != "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No host matches the given filter"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"hosts": hosts})
+	if hosts == nil {
+		hosts = []models.Host{}
+	}
+	body := gin.H{"hosts": hosts, "total": total, "limit": query.Limit, "offset": query.Offset}
+	if notModified(c, services.ETag(body)) {
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+func GetHost(c *gin.Context) {
+	hostName := c.Param("name")
+	host, err := services.CurrentBackend().GetHost(hostName)
+	if err != nil {
+		log.Printf("Error getting host %s: %v", hostName, err)
+		respondError(c, http.StatusInternalServerError, "Failed to get host", err)
+		return
+	}
+	if notModified(c, services.ETag(host)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"host": host})
 }
 
 func AddHost(c *gin.Context) {
@@ -26,12 +62,14 @@ func AddHost(c *gin.Context) {
 		return
 	}
 
-	if err := services.AddHost(host); err != nil {
+	added, err := services.CurrentBackend().AddHost(host)
+	if err != nil {
 		log.Printf("Error adding host %s: %v", host.Name, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to add host"})
+		respondError(c, http.StatusBadRequest, "Failed to add host", err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Host added successfully"})
+	auditHost(c, middleware.AuditAfterKey, added)
+	c.JSON(http.StatusOK, gin.H{"message": "Host added successfully", "host": added, "apply": services.CurrentBackend().HostsChanged()})
 }
 
 func UpdateHost(c *gin.Context) {
@@ -43,21 +81,27 @@ func UpdateHost(c *gin.Context) {
 		return
 	}
 
-	if err := services.UpdateHost(hostName, hostUpdate); err != nil {
+	before, after, err := services.CurrentBackend().UpdateHost(hostName, hostUpdate, precondition(c))
+	auditHost(c, middleware.AuditBeforeKey, before)
+	if err != nil {
 		log.Printf("Error updating host %s: %v", hostName, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to update host"})
+		respondError(c, http.StatusBadRequest, "Failed to update host", err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Host updated successfully"})
+	auditHost(c, middleware.AuditAfterKey, after)
+	c.Header("ETag", services.ETag(after))
+	c.JSON(http.StatusOK, gin.H{"message": "Host updated successfully", "apply": services.CurrentBackend().HostsChanged()})
 }
 
 func DeleteHost(c *gin.Context) {
 	hostName := c.Param("name")
 
-	if err := services.DeleteHost(hostName); err != nil {
+	deleted, err := services.CurrentBackend().DeleteHost(hostName, precondition(c))
+	auditHost(c, middleware.AuditBeforeKey, deleted)
+	if err != nil {
 		log.Printf("Error deleting host %s: %v", hostName, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to delete host"})
+		respondError(c, http.StatusBadRequest, "Failed to delete host", err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Host deleted successfully"})
+	respondWritten(c, "Host deleted successfully", deleted.Name != "", services.CurrentBackend().HostsChanged)
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ListHosts6(c *gin.Context) {
+	hosts, err := services.ListHosts6()
+	if err != nil {
+		log.Printf("Error listing IPv6 hosts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list IPv6 hosts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hosts": hosts})
+}
+
+func AddHost6(c *gin.Context) {
+	var host models.Host6
+	if err := c.ShouldBindJSON(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.AddHost6(host); err != nil {
+		log.Printf("Error adding IPv6 host %s: %v", host.Name, err)
+		respondError(c, http.StatusBadRequest, "Failed to add IPv6 host: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "IPv6 host added successfully", "apply": services.NotifyChanged()})
+}
+
+func UpdateHost6(c *gin.Context) {
+	hostName := c.Param("name")
+	var hostUpdate models.Host6Update
+
+	if err := c.ShouldBindJSON(&hostUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.UpdateHost6(hostName, hostUpdate); err != nil {
+		log.Printf("Error updating IPv6 host %s: %v", hostName, err)
+		respondError(c, http.StatusBadRequest, "Failed to update IPv6 host: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "IPv6 host updated successfully", "apply": services.NotifyChanged()})
+}
+
+func DeleteHost6(c *gin.Context) {
+	hostName := c.Param("name")
+
+	deleted, err := services.DeleteHost6(hostName)
+	if err != nil {
+		log.Printf("Error deleting IPv6 host %s: %v", hostName, err)
+		respondError(c, http.StatusBadRequest, "Failed to delete IPv6 host", err)
+		return
+	}
+	respondWritten(c, "IPv6 host deleted successfully", deleted, services.NotifyChanged)
+}
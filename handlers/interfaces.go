@@ -5,18 +5,22 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/0xPixelNinja/dhcp-rest-api/middleware"
 	"github.com/0xPixelNinja/dhcp-rest-api/models"
 	"github.com/0xPixelNinja/dhcp-rest-api/services"
 	"github.com/gin-gonic/gin"
 )
 
 func ListInterfaces(c *gin.Context) {
-	interfaces, err := services.GetInterfaces()
+	interfaces, err := services.CurrentBackend().GetInterfaces()
 	if err != nil {
 		log.Printf("Error listing interfaces: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list interfaces"})
 		return
 	}
+	if notModified(c, services.ETag(interfaces)) {
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"interfaces": interfaces})
 }
 
@@ -27,12 +31,15 @@ func AddInterface(c *gin.Context) {
 		return
 	}
 
-	if err := services.AddInterface(op.Type, op.Interface); err != nil {
+	auditInterfaces(c, middleware.AuditBeforeKey)
+	changed, err := services.CurrentBackend().AddInterface(op.Type, op.Interface, precondition(c))
+	if err != nil {
 		log.Printf("Error adding interface %s (type %s): %v", op.Interface, op.Type, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to add interface."})
+		respondError(c, http.StatusBadRequest, "Failed to add interface.", err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Interface %s added to INTERFACES%s successfully.", op.Interface, op.Type)})
+	auditInterfaces(c, middleware.AuditAfterKey)
+	respondWritten(c, fmt.Sprintf("Interface %s added to INTERFACES%s successfully.", op.Interface, op.Type), changed, services.CurrentBackend().NotifyChanged)
 }
 
 func DeleteInterface(c *gin.Context) {
@@ -42,10 +49,13 @@ func DeleteInterface(c *gin.Context) {
 		return
 	}
 
-	if err := services.DeleteInterface(op.Type, op.Interface); err != nil {
+	auditInterfaces(c, middleware.AuditBeforeKey)
+	changed, err := services.CurrentBackend().DeleteInterface(op.Type, op.Interface, precondition(c))
+	if err != nil {
 		log.Printf("Error deleting interface %s (type %s): %v", op.Interface, op.Type, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to delete interface."})
+		respondError(c, http.StatusBadRequest, "Failed to delete interface.", err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Interface %s removed from INTERFACES%s successfully.", op.Interface, op.Type)})
+	auditInterfaces(c, middleware.AuditAfterKey)
+	respondWritten(c, fmt.Sprintf("Interface %s removed from INTERFACES%s successfully.", op.Interface, op.Type), changed, services.CurrentBackend().NotifyChanged)
 }
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ListLeases(c *gin.Context) {
+	var filter models.LeaseFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+		return
+	}
+
+	leases, err := services.CurrentBackend().ListLeases(filter)
+	if err != nil {
+		log.Printf("Error listing leases: %v", err)
+		respondError(c, http.StatusInternalServerError, "Failed to list leases: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"leases": leases})
+}
+
+// ReserveLease promotes the active lease of an address to a fixed reservation
+func ReserveLease(c *gin.Context) {
+	ip := c.Param("ip")
+	var req models.LeaseReservation
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	host, err := services.CurrentBackend().ReserveLease(ip, req)
+	if err != nil {
+		log.Printf("Error reserving lease %s: %v", ip, err)
+		respondError(c, http.StatusBadRequest, "Failed to reserve lease: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Lease reserved successfully", "host": host, "apply": services.CurrentBackend().HostsChanged()})
+}
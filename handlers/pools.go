@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ListRanges(c *gin.Context) {
+	network := c.Param("network")
+
+	ranges, err := services.ListRanges(network)
+	if err != nil {
+		log.Printf("Error listing ranges of subnet %s: %v", network, err)
+		respondError(c, http.StatusInternalServerError, "Failed to list ranges: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ranges": ranges})
+}
+
+func AddRange(c *gin.Context) {
+	network := c.Param("network")
+	var r models.Range
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.AddRange(network, r); err != nil {
+		log.Printf("Error adding range %s to subnet %s: %v", r.Start, network, err)
+		respondError(c, http.StatusBadRequest, "Failed to add range: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Range added successfully", "apply": services.NotifyChanged()})
+}
+
+func UpdateRange(c *gin.Context) {
+	network := c.Param("network")
+	start := c.Param("start")
+	var r models.Range
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.UpdateRange(network, start, r); err != nil {
+		log.Printf("Error updating range %s in subnet %s: %v", start, network, err)
+		respondError(c, http.StatusBadRequest, "Failed to update range: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Range updated successfully", "apply": services.NotifyChanged()})
+}
+
+func DeleteRange(c *gin.Context) {
+	network := c.Param("network")
+	start := c.Param("start")
+
+	deleted, err := services.DeleteRange(network, start)
+	if err != nil {
+		log.Printf("Error deleting range %s from subnet %s: %v", start, network, err)
+		respondError(c, http.StatusBadRequest, "Failed to delete range: "+err.Error(), err)
+		return
+	}
+	respondWritten(c, "Range deleted successfully", deleted, services.NotifyChanged)
+}
+
+func ListPools(c *gin.Context) {
+	network := c.Param("network")
+
+	pools, err := services.ListPools(network)
+	if err != nil {
+		log.Printf("Error listing pools of subnet %s: %v", network, err)
+		respondError(c, http.StatusInternalServerError, "Failed to list pools: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pools": pools})
+}
+
+func AddPool(c *gin.Context) {
+	network := c.Param("network")
+	var pool models.Pool
+	if err := c.ShouldBindJSON(&pool); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.AddPool(network, pool); err != nil {
+		log.Printf("Error adding pool to subnet %s: %v", network, err)
+		respondError(c, http.StatusBadRequest, "Failed to add pool: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pool added successfully", "apply": services.NotifyChanged()})
+}
+
+func UpdatePool(c *gin.Context) {
+	network := c.Param("network")
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pool index must be a number"})
+		return
+	}
+	var poolUpdate models.PoolUpdate
+	if err := c.ShouldBindJSON(&poolUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.UpdatePool(network, index, poolUpdate); err != nil {
+		log.Printf("Error updating pool %d in subnet %s: %v", index, network, err)
+		respondError(c, http.StatusBadRequest, "Failed to update pool: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pool updated successfully", "apply": services.NotifyChanged()})
+}
+
+func DeletePool(c *gin.Context) {
+	network := c.Param("network")
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pool index must be a number"})
+		return
+	}
+
+	if err := services.DeletePool(network, index); err != nil {
+		log.Printf("Error deleting pool %d from subnet %s: %v", index, network, err)
+		respondError(c, http.StatusBadRequest, "Failed to delete pool: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pool deleted successfully", "apply": services.NotifyChanged()})
+}
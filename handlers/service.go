@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// respondWritten answers a successful write. The change is applied with
+// notify, and the result included, only if the service reported that it
+// wrote something, so an idempotent request does not restart the server.
+func respondWritten(c *gin.Context, message string, changed bool, notify func() models.ApplyResult) {
+	body := gin.H{"message": message}
+	if changed {
+		body["apply"] = notify()
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// ApplyService restarts/reloads the DHCP server with the current config
+func ApplyService(c *gin.Context) {
+	result := services.CurrentBackend().ApplyChanges()
+	if !result.Success {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply configuration", "apply": result})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Configuration applied successfully", "apply": result})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+func ListSubnets(c *gin.Context) {
+	subnets, err := services.ListSubnets()
+	if err != nil {
+		log.Printf("Error listing subnets: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list subnets"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subnets": subnets})
+}
+
+func AddSubnet(c *gin.Context) {
+	var subnet models.Subnet
+	if err := c.ShouldBindJSON(&subnet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.AddSubnet(subnet); err != nil {
+		log.Printf("Error adding subnet %s: %v", subnet.Network, err)
+		respondError(c, http.StatusBadRequest, "Failed to add subnet: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Subnet added successfully", "apply": services.NotifyChanged()})
+}
+
+func UpdateSubnet(c *gin.Context) {
+	network := c.Param("network")
+	var subnetUpdate models.SubnetUpdate
+
+	if err := c.ShouldBindJSON(&subnetUpdate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload: " + err.Error()})
+		return
+	}
+
+	if err := services.UpdateSubnet(network, subnetUpdate); err != nil {
+		log.Printf("Error updating subnet %s: %v", network, err)
+		respondError(c, http.StatusBadRequest, "Failed to update subnet: "+err.Error(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Subnet updated successfully", "apply": services.NotifyChanged()})
+}
+
+func DeleteSubnet(c *gin.Context) {
+	network := c.Param("network")
+
+	deleted, err := services.DeleteSubnet(network)
+	if err != nil {
+		log.Printf("Error deleting subnet %s: %v", network, err)
+		respondError(c, http.StatusBadRequest, "Failed to delete subnet: "+err.Error(), err)
+		return
+	}
+	respondWritten(c, "Subnet deleted successfully", deleted, services.NotifyChanged)
+}
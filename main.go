@@ -37,28 +37,113 @@ func main() {
 	authedRoutes := r.Group("/")
 	authedRoutes.Use(auth.AuthMiddleware())
 
+	// dhcpd.conf editors without a Kea or dnsmasq counterpart
+	iscOnly := middleware.RequireBackend("isc")
+
 	// DHCP host management
 	hostRoutes := authedRoutes.Group("/hosts")
+	hostRoutes.Use(middleware.Audit())
 	{
 		hostRoutes.GET("/", handlers.ListHosts)
+		hostRoutes.GET("/export", handlers.ExportHosts)
+		hostRoutes.GET("/:name", handlers.GetHost)
 		hostRoutes.POST("/", handlers.AddHost)
+		hostRoutes.POST("/import", iscOnly, handlers.ImportHosts)
 		hostRoutes.PUT("/:name", handlers.UpdateHost)
 		hostRoutes.DELETE("/:name", handlers.DeleteHost)
 	}
 
+	// DHCPv6 host management (dhcpd6.conf)
+	host6Routes := authedRoutes.Group("/v6/hosts")
+	host6Routes.Use(iscOnly)
+	{
+		host6Routes.GET("/", handlers.ListHosts6)
+		host6Routes.POST("/", handlers.AddHost6)
+		host6Routes.PUT("/:name", handlers.UpdateHost6)
+		host6Routes.DELETE("/:name", handlers.DeleteHost6)
+	}
+
+	// Top-level dhcpd.conf parameters
+	authedRoutes.GET("/globals", iscOnly, handlers.GetGlobals)
+	authedRoutes.PUT("/globals", iscOnly, handlers.UpdateGlobals)
+
+	// Subnet declarations
+	subnetRoutes := authedRoutes.Group("/subnets")
+	subnetRoutes.Use(iscOnly)
+	{
+		subnetRoutes.GET("/", handlers.ListSubnets)
+		subnetRoutes.POST("/", handlers.AddSubnet)
+		subnetRoutes.PUT("/:network", handlers.UpdateSubnet)
+		subnetRoutes.DELETE("/:network", handlers.DeleteSubnet)
+
+		// Dynamic ranges and pools within a subnet
+		subnetRoutes.GET("/:network/ranges", handlers.ListRanges)
+		subnetRoutes.POST("/:network/ranges", handlers.AddRange)
+		subnetRoutes.PUT("/:network/ranges/:start", handlers.UpdateRange)
+		subnetRoutes.DELETE("/:network/ranges/:start", handlers.DeleteRange)
+		subnetRoutes.GET("/:network/pools", handlers.ListPools)
+		subnetRoutes.POST("/:network/pools", handlers.AddPool)
+		subnetRoutes.PUT("/:network/pools/:index", handlers.UpdatePool)
+		subnetRoutes.DELETE("/:network/pools/:index", handlers.DeletePool)
+	}
+
+	// Group and shared-network declarations
+	groupRoutes := authedRoutes.Group("/groups")
+	groupRoutes.Use(iscOnly)
+	{
+		groupRoutes.GET("/", handlers.ListGroups)
+		groupRoutes.POST("/", handlers.AddGroup)
+		groupRoutes.PUT("/:name", handlers.UpdateGroup)
+		groupRoutes.DELETE("/:name", handlers.DeleteGroup)
+	}
+
+	sharedNetworkRoutes := authedRoutes.Group("/shared-networks")
+	sharedNetworkRoutes.Use(iscOnly)
+	{
+		sharedNetworkRoutes.GET("/", handlers.ListSharedNetworks)
+		sharedNetworkRoutes.POST("/", handlers.AddSharedNetwork)
+		sharedNetworkRoutes.PUT("/:name", handlers.UpdateSharedNetwork)
+		sharedNetworkRoutes.DELETE("/:name", handlers.DeleteSharedNetwork)
+	}
+
+	// Lease database
+	leaseRoutes := authedRoutes.Group("/leases")
+	{
+		leaseRoutes.GET("/", handlers.ListLeases)
+		leaseRoutes.POST("/:ip/reserve", handlers.ReserveLease)
+	}
+
 	// Network interface management
 	interfaceRoutes := authedRoutes.Group("/interfaces")
+	interfaceRoutes.Use(middleware.Audit())
 	{
 		interfaceRoutes.GET("/", handlers.ListInterfaces)
 		interfaceRoutes.POST("/", handlers.AddInterface)
 		interfaceRoutes.DELETE("/", handlers.DeleteInterface)
 	}
 
+	// Config snapshots and rollback
+	historyRoutes := authedRoutes.Group("/history")
+	{
+		historyRoutes.GET("/", handlers.ListHistory)
+		historyRoutes.GET("/:id", handlers.GetHistory)
+		historyRoutes.POST("/:id/restore", handlers.RestoreHistory)
+	}
+
+	// All-or-nothing host and interface changes
+	authedRoutes.POST("/batch", iscOnly, middleware.Audit(), handlers.ApplyBatch)
+
+	// Audit log of host and interface changes
+	authedRoutes.GET("/audit", handlers.ListAudit)
+
+	// DHCP service control
+	authedRoutes.POST("/service/apply", handlers.ApplyService)
+
 	// Token management
 	// authedRoutes.GET("/token", handlers.GetToken)
 	// authedRoutes.PUT("/token", handlers.UpdateToken)
 
-	log.Printf("Starting DHCP REST API server on port %s", config.AppConfig.Port)
+	log.Printf("Starting DHCP REST API server on port %s (%s backend)", config.AppConfig.Port, config.AppConfig.Backend)
 	if err := r.Run(":" + config.AppConfig.Port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/auth"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+	"github.com/0xPixelNinja/dhcp-rest-api/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys handlers use to hand the affected object's state to Audit
+const (
+	AuditBeforeKey = "audit_before"
+	AuditAfterKey  = "audit_after"
+)
+
+// Audit writes an audit log entry for every POST, PUT and DELETE that
+// passes through it. Must run after auth.AuthMiddleware.
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		start := time.Now().UTC()
+		c.Next()
+
+		entry := models.AuditEntry{
+			Time:     start,
+			Actor:    c.GetString(auth.ActorKey),
+			ClientIP: c.ClientIP(),
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			Status:   c.Writer.Status(),
+		}
+		entry.Success = entry.Status < http.StatusBadRequest
+		if err := c.Errors.Last(); err != nil {
+			entry.Error = err.Error()
+		}
+		entry.Before, _ = c.Get(AuditBeforeKey)
+		entry.After, _ = c.Get(AuditAfterKey)
+
+		if err := services.RecordAudit(entry); err != nil {
+			log.Printf("ERROR: could not record audit entry for %s %s by %s: %v", entry.Method, entry.Path, entry.Actor, err)
+		}
+	}
+}
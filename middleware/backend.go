@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireBackend answers 501 for routes that only work with some of the
+// DHCP backends, such as the dhcpd.conf subnet and group editors
+func RequireBackend(backends ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(backends, config.AppConfig.Backend) {
+			c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "Not supported by the " + config.AppConfig.Backend + " backend"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,24 +1,335 @@
 package models
 
-// Host represents a DHCP host entry
+import "time"
+
+// Host represents a DHCP host entry.
+// FixedAddress may be "auto", or omitted in favour of Subnet (a declared
+// subnet in CIDR notation), to have the server pick the lowest free
+// address. Routers, netmask and DNS servers default to those of the
+// subnet containing the address.
 type Host struct {
-	Name                    string `json:"name" binding:"required"`
-	HardwareEthernet        string `json:"hardware_ethernet" binding:"required"`
-	OptionRouters           string `json:"option_routers" binding:"required"`
-	OptionSubnetMask        string `json:"option_subnet_mask" binding:"required"`
-	FixedAddress            string `json:"fixed_address" binding:"required"`
-	OptionDomainNameServers string `json:"option_domain_name_servers" binding:"required"`
+	Name                    string    `json:"name" binding:"required"`
+	HardwareEthernet        string    `json:"hardware_ethernet" binding:"required"`
+	OptionRouters           string    `json:"option_routers"`
+	OptionSubnetMask        string    `json:"option_subnet_mask"`
+	FixedAddress            string    `json:"fixed_address"`
+	OptionDomainNameServers string    `json:"option_domain_name_servers"`
+	Options                 Options   `json:"options,omitempty"`
+	Boot                    *HostBoot `json:"boot,omitempty"`
+	Group                   string    `json:"group,omitempty"`
+	SharedNetwork           string    `json:"shared_network,omitempty"`
+	Subnet                  string    `json:"subnet,omitempty"`
 }
 
 // HostUpdate contains optional fields for updating a host
-// Using pointers to distinguish between empty string and not provided
+// Using pointers to distinguish between empty string and not provided.
+// Options are merged into the existing ones; an empty value removes one.
+// Boot replaces all boot settings; an empty object removes them.
 type HostUpdate struct {
+	Name                    *string   `json:"name,omitempty"`
+	HardwareEthernet        *string   `json:"hardware_ethernet,omitempty"`
+	OptionRouters           *string   `json:"option_routers,omitempty"`
+	OptionSubnetMask        *string   `json:"option_subnet_mask,omitempty"`
+	FixedAddress            *string   `json:"fixed_address,omitempty"`
+	OptionDomainNameServers *string   `json:"option_domain_name_servers,omitempty"`
+	Options                 Options   `json:"options,omitempty"`
+	Boot                    *HostBoot `json:"boot,omitempty"`
+	Group                   *string   `json:"group,omitempty"`
+}
+
+// HostBoot holds the network boot settings of a host. When IPXEFilename is
+// set, iPXE clients are chainloaded to it and other clients get Filename.
+type HostBoot struct {
+	NextServer     string `json:"next_server,omitempty"`
+	Filename       string `json:"filename,omitempty"`
+	TFTPServerName string `json:"tftp_server_name,omitempty"`
+	BootfileName   string `json:"bootfile_name,omitempty"`
+	IPXEFilename   string `json:"ipxe_filename,omitempty"`
+}
+
+// Host6 represents a DHCPv6 host reservation in dhcpd6.conf. A host is
+// identified by its DUID (ClientID) or its hardware address.
+type Host6 struct {
+	Name             string `json:"name" binding:"required"`
+	ClientID         string `json:"client_id,omitempty"`
+	HardwareEthernet string `json:"hardware_ethernet,omitempty"`
+	FixedAddress6    string `json:"fixed_address6,omitempty"`
+	FixedPrefix6     string `json:"fixed_prefix6,omitempty"`
+}
+
+// Host6Update contains optional fields for updating a DHCPv6 host
+type Host6Update struct {
+	Name             *string `json:"name,omitempty"`
+	ClientID         *string `json:"client_id,omitempty"`
+	HardwareEthernet *string `json:"hardware_ethernet,omitempty"`
+	FixedAddress6    *string `json:"fixed_address6,omitempty"`
+	FixedPrefix6     *string `json:"fixed_prefix6,omitempty"`
+}
+
+// Subnet represents a subnet declaration
+type Subnet struct {
+	Network                 string  `json:"network" binding:"required"`
+	Netmask                 string  `json:"netmask" binding:"required"`
+	OptionRouters           string  `json:"option_routers,omitempty"`
+	OptionDomainNameServers string  `json:"option_domain_name_servers,omitempty"`
+	OptionDomainName        string  `json:"option_domain_name,omitempty"`
+	DefaultLeaseTime        int     `json:"default_lease_time,omitempty"`
+	MaxLeaseTime            int     `json:"max_lease_time,omitempty"`
+	Authoritative           bool    `json:"authoritative"`
+	DenyUnknownClients      bool    `json:"deny_unknown_clients"`
+	Ranges                  []Range `json:"ranges,omitempty" binding:"omitempty,dive"`
+	Pools                   []Pool  `json:"pools,omitempty" binding:"omitempty,dive"`
+	SharedNetwork           string  `json:"shared_network,omitempty"`
+}
+
+// SubnetUpdate contains optional fields for updating a subnet
+type SubnetUpdate struct {
+	Network                 *string `json:"network,omitempty"`
+	Netmask                 *string `json:"netmask,omitempty"`
+	OptionRouters           *string `json:"option_routers,omitempty"`
+	OptionDomainNameServers *string `json:"option_domain_name_servers,omitempty"`
+	OptionDomainName        *string `json:"option_domain_name,omitempty"`
+	DefaultLeaseTime        *int    `json:"default_lease_time,omitempty"`
+	MaxLeaseTime            *int    `json:"max_lease_time,omitempty"`
+	Authoritative           *bool   `json:"authoritative,omitempty"`
+	DenyUnknownClients      *bool   `json:"deny_unknown_clients,omitempty"`
+}
+
+// Range is a dynamic address range; End may be omitted for a single address
+type Range struct {
+	Start        string `json:"start" binding:"required"`
+	End          string `json:"end,omitempty"`
+	DynamicBootp bool   `json:"dynamic_bootp,omitempty"`
+}
+
+// Pool is a pool declaration inside a subnet. Allow and Deny hold the
+// permit lists, e.g. "unknown-clients" or "members of \"vip\"".
+type Pool struct {
+	Ranges       []Range  `json:"ranges" binding:"required,min=1,dive"`
+	Allow        []string `json:"allow,omitempty"`
+	Deny         []string `json:"deny,omitempty"`
+	FailoverPeer string   `json:"failover_peer,omitempty"`
+}
+
+// PoolUpdate contains optional fields for updating a pool
+type PoolUpdate struct {
+	Ranges       *[]Range  `json:"ranges,omitempty" binding:"omitempty,min=1,dive"`
+	Allow        *[]string `json:"allow,omitempty"`
+	Deny         *[]string `json:"deny,omitempty"`
+	FailoverPeer *string   `json:"failover_peer,omitempty"`
+}
+
+// Group represents a group declaration whose parameters are shared by the
+// hosts inside it. Subnet places a new group inside that subnet.
+type Group struct {
+	Name                    string   `json:"name" binding:"required"`
+	Subnet                  string   `json:"subnet,omitempty"`
+	OptionRouters           string   `json:"option_routers,omitempty"`
+	OptionSubnetMask        string   `json:"option_subnet_mask,omitempty"`
+	OptionDomainNameServers string   `json:"option_domain_name_servers,omitempty"`
+	OptionDomainName        string   `json:"option_domain_name,omitempty"`
+	DefaultLeaseTime        int      `json:"default_lease_time,omitempty"`
+	MaxLeaseTime            int      `json:"max_lease_time,omitempty"`
+	Hosts                   []string `json:"hosts,omitempty"`
+}
+
+// GroupUpdate contains optional fields for updating a group
+type GroupUpdate struct {
 	Name                    *string `json:"name,omitempty"`
-	HardwareEthernet        *string `json:"hardware_ethernet,omitempty"`
 	OptionRouters           *string `json:"option_routers,omitempty"`
 	OptionSubnetMask        *string `json:"option_subnet_mask,omitempty"`
-	FixedAddress            *string `json:"fixed_address,omitempty"`
 	OptionDomainNameServers *string `json:"option_domain_name_servers,omitempty"`
+	OptionDomainName        *string `json:"option_domain_name,omitempty"`
+	DefaultLeaseTime        *int    `json:"default_lease_time,omitempty"`
+	MaxLeaseTime            *int    `json:"max_lease_time,omitempty"`
+}
+
+// SharedNetwork represents a shared-network declaration; Subnets lists the
+// network addresses of the subnets inside it
+type SharedNetwork struct {
+	Name    string   `json:"name" binding:"required"`
+	Subnets []string `json:"subnets" binding:"required,min=1"`
+}
+
+// SharedNetworkUpdate contains optional fields for updating a shared network
+type SharedNetworkUpdate struct {
+	Name    *string   `json:"name,omitempty"`
+	Subnets *[]string `json:"subnets,omitempty" binding:"omitempty,min=1"`
+}
+
+// Globals holds the top-level parameters of dhcpd.conf
+type Globals struct {
+	OptionDomainName        string `json:"option_domain_name,omitempty"`
+	OptionDomainNameServers string `json:"option_domain_name_servers,omitempty"`
+	DefaultLeaseTime        int    `json:"default_lease_time,omitempty"`
+	MaxLeaseTime            int    `json:"max_lease_time,omitempty"`
+	Authoritative           bool   `json:"authoritative"`
+	DdnsUpdateStyle         string `json:"ddns_update_style,omitempty"`
+	LogFacility             string `json:"log_facility,omitempty"`
+}
+
+// GlobalsUpdate contains optional fields for updating the global parameters
+type GlobalsUpdate struct {
+	OptionDomainName        *string `json:"option_domain_name,omitempty"`
+	OptionDomainNameServers *string `json:"option_domain_name_servers,omitempty"`
+	DefaultLeaseTime        *int    `json:"default_lease_time,omitempty"`
+	MaxLeaseTime            *int    `json:"max_lease_time,omitempty"`
+	Authoritative           *bool   `json:"authoritative,omitempty"`
+	DdnsUpdateStyle         *string `json:"ddns_update_style,omitempty" binding:"omitempty,oneof=none ad-hoc interim standard"`
+	LogFacility             *string `json:"log_facility,omitempty"`
+}
+
+// Lease is the current state of an address in dhcpd.leases. Ends is nil
+// for leases that never expire.
+type Lease struct {
+	IP               string     `json:"ip"`
+	Starts           *time.Time `json:"starts,omitempty"`
+	Ends             *time.Time `json:"ends,omitempty"`
+	BindingState     string     `json:"binding_state"`
+	HardwareEthernet string     `json:"hardware_ethernet,omitempty"`
+	ClientHostname   string     `json:"client_hostname,omitempty"`
+	UID              string     `json:"uid,omitempty"`
+}
+
+// HostFilter narrows down a host listing; empty fields match everything.
+// MAC may be given in any common notation. Name matches as a
+// case-insensitive substring, or as a glob when it contains *, ? or [.
+type HostFilter struct {
+	MAC    string `form:"mac" binding:"omitempty,mac"`
+	IP     string `form:"ip" binding:"omitempty,ipv4"`
+	Subnet string `form:"subnet" binding:"omitempty,cidrv4"`
+	Name   string `form:"name"`
+}
+
+// HostQuery is a filtered, sorted and paginated host listing. Sort is
+// name, ip or mac, prefixed with - for descending order. A zero Limit
+// returns every host from Offset on.
+type HostQuery struct {
+	HostFilter
+	Sort   string `form:"sort" binding:"omitempty,oneof=name ip mac -name -ip -mac"`
+	Limit  int    `form:"limit" binding:"min=0"`
+	Offset int    `form:"offset" binding:"min=0"`
+}
+
+// LeaseFilter narrows down a lease listing; empty fields match everything
+type LeaseFilter struct {
+	MAC      string `form:"mac" binding:"omitempty,mac"`
+	IP       string `form:"ip" binding:"omitempty,ip"`
+	State    string `form:"state"`
+	Subnet   string `form:"subnet" binding:"omitempty,cidr"`
+	Hostname string `form:"hostname"`
+}
+
+// LeaseReservation is the optional body of a lease promotion. Name
+// defaults to the lease's client-hostname.
+type LeaseReservation struct {
+	Name  string `json:"name,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// ApplyResult reports how a configuration change was applied to the
+// running DHCP server. Pending is set when the restart is deferred
+// (debounce mode) or left to an explicit POST /service/apply (manual mode).
+type ApplyResult struct {
+	Mode     string `json:"mode"`
+	Pending  bool   `json:"pending,omitempty"`
+	Command  string `json:"command,omitempty"`
+	Success  bool   `json:"success"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HistoryEntry is a stored snapshot of a managed config file, taken just
+// before the file was changed. File is one of dhcpd.conf, dhcpd6.conf or
+// interfaces.
+type HistoryEntry struct {
+	ID   string    `json:"id"`
+	File string    `json:"file"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// HistoryDetail is a snapshot with its content and a unified diff against
+// the previous snapshot of the same file
+type HistoryDetail struct {
+	HistoryEntry
+	Previous string `json:"previous,omitempty"`
+	Diff     string `json:"diff"`
+	Content  string `json:"content"`
+}
+
+// AuditEntry is one line of the audit log: who changed what, when, and
+// how it went. Before and After hold the affected host or interface
+// state; either is absent when the object did not exist.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor"`
+	ClientIP string    `json:"client_ip"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	Before   any       `json:"before,omitempty"`
+	After    any       `json:"after,omitempty"`
+}
+
+// AuditFilter selects audit entries in GET /audit
+type AuditFilter struct {
+	Since *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until *time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Actor string     `form:"actor"`
+}
+
+// BatchOperation is one step of a POST /batch request. Target is host or
+// interface. Host adds use Host; host updates and deletes name the host in
+// Name and updates carry Update. Interface operations use Type and
+// Interface like InterfaceOperation.
+type BatchOperation struct {
+	Op        string      `json:"op" binding:"required,oneof=add update delete"`
+	Target    string      `json:"target" binding:"required,oneof=host interface"`
+	Name      string      `json:"name,omitempty"`
+	Host      *Host       `json:"host,omitempty"`
+	Update    *HostUpdate `json:"update,omitempty"`
+	Type      string      `json:"type,omitempty" binding:"omitempty,oneof=v4 v6"`
+	Interface string      `json:"interface,omitempty"`
+}
+
+// BatchRequest is an ordered list of operations applied all-or-nothing
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+// BatchResult reports one applied operation. Host is the host as written
+// by an add or update.
+type BatchResult struct {
+	Op     string `json:"op"`
+	Target string `json:"target"`
+	Name   string `json:"name"`
+	Host   *Host  `json:"host,omitempty"`
+}
+
+// ImportRow reports what happened to one record of a host import. Row is
+// the 1-based record number, not counting a CSV header. Status is added,
+// overwritten, skipped or error.
+type ImportRow struct {
+	Row    int    `json:"row"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"`
+	Field  string `json:"field,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport summarises a host import. Nothing is written on a dry run
+// or when any row failed.
+type ImportReport struct {
+	DryRun      bool        `json:"dry_run"`
+	Added       int         `json:"added"`
+	Overwritten int         `json:"overwritten"`
+	Skipped     int         `json:"skipped"`
+	Failed      int         `json:"failed"`
+	Rows        []ImportRow `json:"rows"`
 }
 
 // InterfaceOperation is used for adding or deleting network interfaces
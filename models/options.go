@@ -0,0 +1,96 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Option is an extra statement on a host, keyed by its leading keywords,
+// e.g. Name "option host-name" and Value "web01"
+type Option struct {
+	Name  string
+	Value string
+}
+
+// Options is an ordered map of extra host statements. It is encoded as a
+// JSON object and keeps the key order of the config file or request.
+type Options []Option
+
+// Get returns the value stored under name
+func (o Options) Get(name string) (string, bool) {
+	for _, opt := range o {
+		if opt.Name == name {
+			return opt.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces the value stored under name, or appends it
+func (o *Options) Set(name, value string) {
+	for i := range *o {
+		if (*o)[i].Name == name {
+			(*o)[i].Value = value
+			return
+		}
+	}
+	*o = append(*o, Option{Name: name, Value: value})
+}
+
+func (o Options) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, opt := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(opt.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(opt.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o *Options) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*o = nil
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("options must be a JSON object")
+	}
+
+	opts := Options{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+		var value string
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("option %q: value must be a string", name)
+		}
+		opts.Set(name, value)
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	*o = opts
+	return nil
+}
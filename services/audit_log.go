@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+var auditMu sync.Mutex
+
+// RecordAudit appends an entry to the JSON-lines audit log. The file is
+// only ever opened for appending.
+func RecordAudit(entry models.AuditEntry) error {
+	path := config.AppConfig.AuditLogPath
+	if path == "" {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return file.Close()
+}
+
+// ListAudit reads the audit log in chronological order, keeping entries
+// that match filter
+func ListAudit(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	entries := []models.AuditEntry{}
+	path := config.AppConfig.AuditLogPath
+	if path == "" {
+		return entries, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		log.Printf("Error opening audit log %s: %v", path, err)
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // entries carry whole hosts
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("Warning: skipping malformed audit log line %d: %v", lineNo, err)
+			continue
+		}
+		if filter.Since != nil && entry.Time.Before(*filter.Since) {
+			continue
+		}
+		if filter.Until != nil && entry.Time.After(*filter.Until) {
+			continue
+		}
+		if filter.Actor != "" && entry.Actor != filter.Actor {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error scanning audit log %s: %v", path, err)
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// Backend is a DHCP server whose reservations, interfaces and leases the
+// API manages. BACKEND selects the implementation: isc (the default) edits
+// dhcpd.conf, kea edits the reservations in kea-dhcp4.conf, kea-ctrl
+// changes them live through the Kea control API and dnsmasq manages
+// dhcp-host lines in a conf.d file.
+type Backend interface {
+	ListHosts(query models.HostQuery) ([]models.Host, int, error)
+	GetHost(name string) (models.Host, error)
+	// AddHost, UpdateHost and DeleteHost return the affected host as
+	// stored, read under the same lock as the change
+	AddHost(host models.Host) (models.Host, error)
+	UpdateHost(name string, updates models.HostUpdate, cond Precondition) (before, after models.Host, err error)
+	DeleteHost(name string, cond Precondition) (models.Host, error)
+
+	GetInterfaces() (map[string]string, error)
+	// AddInterface and DeleteInterface report whether the list changed
+	AddInterface(ifaceType, ifaceName string, cond Precondition) (bool, error)
+	DeleteInterface(ifaceType, ifaceName string, cond Precondition) (bool, error)
+
+	ListLeases(filter models.LeaseFilter) ([]models.Lease, error)
+	ReserveLease(ip string, req models.LeaseReservation) (models.Host, error)
+
+	// ApplyChanges makes the server pick up the current configuration now;
+	// NotifyChanged does so according to APPLY_MODE after a write.
+	// HostsChanged is NotifyChanged for a reservation write, which a
+	// backend changing reservations live does not need to apply.
+	ApplyChanges() models.ApplyResult
+	NotifyChanged() models.ApplyResult
+	HostsChanged() models.ApplyResult
+}
+
+// CurrentBackend returns the backend selected by BACKEND
+func CurrentBackend() Backend {
+	switch config.AppConfig.Backend {
+	case "kea":
+		return keaBackend{}
+	case "kea-ctrl":
+		return keaCtrlBackend{}
+	case "dnsmasq":
+		return dnsmasqBackend{}
+	default:
+		return iscBackend{}
+	}
+}
+
+// iscBackend manages ISC dhcpd through the package-level functions
+type iscBackend struct{}
+
+func (iscBackend) ListHosts(query models.HostQuery) ([]models.Host, int, error) {
+	return ListHosts(query)
+}
+
+func (iscBackend) GetHost(name string) (models.Host, error) { return GetHost(name) }
+
+func (iscBackend) AddHost(host models.Host) (models.Host, error) { return AddHost(host) }
+
+func (iscBackend) UpdateHost(name string, updates models.HostUpdate, cond Precondition) (models.Host, models.Host, error) {
+	return UpdateHost(name, updates, cond)
+}
+
+func (iscBackend) DeleteHost(name string, cond Precondition) (models.Host, error) {
+	return DeleteHost(name, cond)
+}
+
+func (iscBackend) GetInterfaces() (map[string]string, error) { return GetInterfaces() }
+
+func (iscBackend) AddInterface(ifaceType, ifaceName string, cond Precondition) (bool, error) {
+	return AddInterface(ifaceType, ifaceName, cond)
+}
+
+func (iscBackend) DeleteInterface(ifaceType, ifaceName string, cond Precondition) (bool, error) {
+	return DeleteInterface(ifaceType, ifaceName, cond)
+}
+
+func (iscBackend) ListLeases(filter models.LeaseFilter) ([]models.Lease, error) {
+	return ListLeases(filter)
+}
+
+func (iscBackend) ReserveLease(ip string, req models.LeaseReservation) (models.Host, error) {
+	return ReserveLease(ip, req)
+}
+
+func (iscBackend) ApplyChanges() models.ApplyResult { return ApplyChanges() }
+
+func (iscBackend) NotifyChanged() models.ApplyResult { return NotifyChanged() }
+
+func (iscBackend) HostsChanged() models.ApplyResult { return NotifyChanged() }
+
+// queryHosts filters, sorts and pages hosts and returns the page together
+// with the number of matches before paging
+func queryHosts(hosts []models.Host, query models.HostQuery) ([]models.Host, int, error) {
+	hosts, err := filterHosts(hosts, query.HostFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	sortHosts(hosts, query.Sort)
+
+	total := len(hosts)
+	start := min(query.Offset, total)
+	end := total
+	if query.Limit > 0 {
+		end = min(start+query.Limit, total)
+	}
+	return hosts[start:end], total, nil
+}
+
+// findHostIn returns the named host from a host list
+func findHostIn(hosts []models.Host, name string) (models.Host, error) {
+	for _, host := range hosts {
+		if host.Name == name {
+			return host, nil
+		}
+	}
+	return models.Host{}, fmt.Errorf("host %s %w", name, ErrNotFound)
+}
+
+// hostETagIn returns the entity tag of the named host as served by GET
+// /hosts/:name, or "" if hosts has no such host
+func hostETagIn(hosts []models.Host, name string) string {
+	if host, err := findHostIn(hosts, name); err == nil {
+		return ETag(host)
+	}
+	return ""
+}
+
+// hostConflictIn returns a ConflictError for the first host in hosts that
+// shares its name, MAC or a fixed address with host. Every backend checks
+// reservations with it. self is the name of the host being updated, if
+// any, and is skipped.
+func hostConflictIn(hosts []models.Host, host models.Host, self string) error {
+	for _, other := range hosts {
+		if self != "" && other.Name == self {
+			continue
+		}
+		switch {
+		case other.Name != "" && other.Name == host.Name:
+			return &ConflictError{"name", host.Name, other}
+		case host.HardwareEthernet != "" && strings.EqualFold(other.HardwareEthernet, host.HardwareEthernet):
+			return &ConflictError{"hardware_ethernet", host.HardwareEthernet, other}
+		}
+		if addr := sharedAddress(other.FixedAddress, host.FixedAddress); addr != "" {
+			return &ConflictError{"fixed_address", addr, other}
+		}
+	}
+	return nil
+}
+
+// mergeHostUpdate applies updates to host the way updateHost does to a
+// host declaration
+func mergeHostUpdate(host *models.Host, updates models.HostUpdate) {
+	if updates.Name != nil && *updates.Name != "" {
+		host.Name = *updates.Name
+	}
+	for _, p := range hostParams {
+		if value := p.update(&updates); value != nil {
+			*p.field(host) = *value
+		}
+	}
+	for _, opt := range updates.Options {
+		if opt.Value == "" {
+			host.Options = slices.DeleteFunc(host.Options, func(o models.Option) bool { return o.Name == opt.Name })
+			continue
+		}
+		host.Options.Set(opt.Name, opt.Value)
+	}
+	if updates.Boot != nil {
+		host.Boot = updates.Boot
+		if *host.Boot == (models.HostBoot{}) {
+			host.Boot = nil
+		}
+	}
+	if updates.Group != nil {
+		host.Group = *updates.Group
+	}
+}
+
+// checkPortableHost validates a host for the kea and dnsmasq backends,
+// which support a single fixed address and neither groups nor allocation
+func checkPortableHost(host *models.Host, backend string) error {
+	if host.Group != "" {
+		return &FieldError{"group", "groups are only supported by the isc backend, not " + backend}
+	}
+	if host.FixedAddress == autoAddress || host.Subnet != "" {
+		return &FieldError{"fixed_address", "address allocation is only supported by the isc backend, not " + backend}
+	}
+	if host.FixedAddress == "" {
+		return &FieldError{"fixed_address", "required"}
+	}
+	if err := validateHost(host); err != nil {
+		return err
+	}
+	if len(splitAddresses(host.FixedAddress)) > 1 {
+		return &FieldError{"fixed_address", backend + " reservations take a single address"}
+	}
+	if host.Boot != nil && host.Boot.IPXEFilename != "" {
+		return &FieldError{"boot.ipxe_filename", "iPXE chainloading is only supported by the isc backend, not " + backend}
+	}
+	return nil
+}
+
+// leaseHost builds the reservation that promotes the lease of ip in
+// leases, as described by ReserveLease
+func leaseHost(leases []models.Lease, ip string, req models.LeaseReservation) (models.Host, error) {
+	var lease *models.Lease
+	for i := range leases {
+		if leases[i].IP == ip {
+			lease = &leases[i]
+		}
+	}
+	if lease == nil {
+		return models.Host{}, fmt.Errorf("lease for %s %w", ip, ErrNotFound)
+	}
+	if lease.BindingState != "active" {
+		return models.Host{}, fmt.Errorf("lease for %s is %s, not active", ip, lease.BindingState)
+	}
+	if lease.HardwareEthernet == "" {
+		return models.Host{}, fmt.Errorf("lease for %s has no hardware address", ip)
+	}
+
+	host := models.Host{
+		Name:             req.Name,
+		HardwareEthernet: lease.HardwareEthernet,
+		FixedAddress:     lease.IP,
+		Group:            req.Group,
+	}
+	if host.Name == "" {
+		host.Name = lease.ClientHostname
+	}
+	if host.Name == "" {
+		return models.Host{}, fmt.Errorf("lease for %s has no client-hostname, a name is required", ip)
+	}
+	return host, nil
+}
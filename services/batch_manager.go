@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// BatchError reports the operation that made a batch fail. Nothing from
+// the batch has been written.
+type BatchError struct {
+	Index int // zero-based position in the request
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyBatch applies host and interface operations in order on in-memory
+// copies of dhcpd.conf and the interfaces file, then writes each file at
+// most once. Both files are staged and checked before either is replaced.
+func ApplyBatch(ops []models.BatchOperation) ([]models.BatchResult, error) {
+	var results []models.BatchResult
+	dhcp, ifaces := dhcpConfFile(), interfacesFile()
+
+	err := withFileLock(dhcp.path, func() error {
+		return withFileLock(ifaces.path, func() error {
+			f, err := loadConfFile(dhcp.path)
+			if err != nil {
+				return err
+			}
+			interfaces, err := GetInterfaces()
+			if err != nil {
+				return err
+			}
+
+			var hostsChanged, interfacesChanged bool
+			for i, op := range ops {
+				result, err := applyBatchOperation(f, interfaces, op)
+				if errors.Is(err, errUnchanged) {
+					err = nil
+				} else if err == nil {
+					if op.Target == "host" {
+						hostsChanged = true
+					} else {
+						interfacesChanged = true
+					}
+				}
+				if err != nil {
+					return &BatchError{i, err}
+				}
+				results = append(results, result)
+			}
+
+			var writes []confWrite
+			if hostsChanged {
+				writes = append(writes, confWrite{dhcp, f.Bytes()})
+			}
+			if interfacesChanged {
+				content, err := interfacesContent(interfaces)
+				if err != nil {
+					return err
+				}
+				writes = append(writes, confWrite{ifaces, content})
+			}
+			return replaceConfFiles(writes...)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// applyBatchOperation performs one operation. It returns errUnchanged for
+// deletes of things that are already gone.
+func applyBatchOperation(f *dhcpconf.File, interfaces map[string]string, op models.BatchOperation) (models.BatchResult, error) {
+	result := models.BatchResult{Op: op.Op, Target: op.Target, Name: op.Name}
+
+	if op.Target == "interface" {
+		if op.Type == "" || op.Interface == "" {
+			return result, &FieldError{"interface", "type and interface are required"}
+		}
+		result.Name = op.Interface
+		if op.Op == "add" {
+			return result, addInterface(interfaces, op.Type, op.Interface)
+		}
+		if op.Op == "delete" {
+			return result, deleteInterface(interfaces, op.Type, op.Interface)
+		}
+		return result, &FieldError{"op", "interfaces can only be added or deleted"}
+	}
+
+	var err error
+	switch op.Op {
+	case "add":
+		if op.Host == nil {
+			return result, &FieldError{"host", "required for add"}
+		}
+		host := *op.Host
+		if err = addHost(f, &host); err == nil {
+			result.Name = host.Name
+			result.Host = &host
+		}
+		return result, err
+	case "update":
+		if op.Name == "" || op.Update == nil {
+			return result, &FieldError{"update", "name and update are required"}
+		}
+		if err = updateHost(f, op.Name, *op.Update); err != nil {
+			return result, err
+		}
+		if op.Update.Name != nil && *op.Update.Name != "" {
+			result.Name = *op.Update.Name
+		}
+	case "delete":
+		if op.Name == "" {
+			return result, &FieldError{"name", "required for delete"}
+		}
+		return result, deleteHost(f, op.Name)
+	}
+
+	for _, host := range listHosts(f) {
+		if host.Name == result.Name {
+			result.Host = &host
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+const batchDhcpConf = "host web {\n    hardware ethernet 00:11:22:33:44:55;\n    fixed-address 10.0.0.5;\n}\n"
+
+const batchInterfaces = "INTERFACESv4=\"eth0\"\nINTERFACESv6=\"\"\n"
+
+func TestApplyBatch(t *testing.T) {
+	addHost := models.BatchOperation{Op: "add", Target: "host", Host: &models.Host{
+		Name: "db", HardwareEthernet: "00:11:22:33:44:66", FixedAddress: "10.0.0.6",
+	}}
+	addInterface := models.BatchOperation{Op: "add", Target: "interface", Type: "v4", Interface: "eth1"}
+	address := "10.0.0.7"
+	updateMissing := models.BatchOperation{Op: "update", Target: "host", Name: "missing", Update: &models.HostUpdate{FixedAddress: &address}}
+
+	tests := []struct {
+		name         string
+		ops          []models.BatchOperation
+		checkCommand string
+		ok           bool
+		failedIndex  int  // the operation that fails, if any
+		validation   bool // the syntax check fails instead
+	}{
+		{"success", []models.BatchOperation{addHost, addInterface}, "", true, 0, false},
+		{"second operation fails", []models.BatchOperation{addHost, addInterface, updateMissing}, "", false, 2, false},
+		{"syntax check rejects dhcpd.conf", []models.BatchOperation{addInterface, addHost}, "false", false, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dhcpPath := useDhcpConf(t, batchDhcpConf)
+			config.AppConfig.CheckCommand = tt.checkCommand
+			ifacesPath := filepath.Join(t.TempDir(), "isc-dhcp-server")
+			if err := os.WriteFile(ifacesPath, []byte(batchInterfaces), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			config.AppConfig.InterfacesConfPath = ifacesPath
+
+			results, err := ApplyBatch(tt.ops)
+			dhcpContent, _ := os.ReadFile(dhcpPath)
+			ifacesContent, _ := os.ReadFile(ifacesPath)
+
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("ApplyBatch: %v", err)
+				}
+				if len(results) != 2 || results[0].Host == nil || results[0].Host.Name != "db" {
+					t.Errorf("results = %+v", results)
+				}
+				if !strings.Contains(string(dhcpContent), "host db {") {
+					t.Errorf("dhcpd.conf lacks the new host:\n%s", dhcpContent)
+				}
+				if !strings.Contains(string(ifacesContent), `INTERFACESv4="eth0 eth1"`) {
+					t.Errorf("interfaces file lacks eth1:\n%s", ifacesContent)
+				}
+				return
+			}
+
+			var batchErr *BatchError
+			var validationErr *ValidationError
+			switch {
+			case tt.validation && !errors.As(err, &validationErr):
+				t.Errorf("ApplyBatch error = %v, want a ValidationError", err)
+			case !tt.validation && (!errors.As(err, &batchErr) || batchErr.Index != tt.failedIndex):
+				t.Errorf("ApplyBatch error = %v, want operation %d to fail", err, tt.failedIndex)
+			}
+			if results != nil {
+				t.Errorf("results = %+v, want none", results)
+			}
+			if string(dhcpContent) != batchDhcpConf {
+				t.Errorf("dhcpd.conf changed:\n%s", dhcpContent)
+			}
+			if string(ifacesContent) != batchInterfaces {
+				t.Errorf("interfaces file changed:\n%s", ifacesContent)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+)
+
+// confFile is a configuration file managed by the API together with the
+// command used to syntax-check candidate versions of it. name identifies
+// the file in the history store.
+type confFile struct {
+	name         string
+	path         string
+	checkCommand string
+}
+
+func dhcpConfFile() confFile {
+	return confFile{"dhcpd.conf", config.AppConfig.DhcpConfPath, config.AppConfig.CheckCommand}
+}
+
+func dhcp6ConfFile() confFile {
+	return confFile{"dhcpd6.conf", config.AppConfig.Dhcp6ConfPath, config.AppConfig.Check6Command}
+}
+
+// interfacesFile has no syntax checker
+func interfacesFile() confFile {
+	return confFile{"interfaces", config.AppConfig.InterfacesConfPath, ""}
+}
+
+// managedFile looks up a managed file by its history name
+func managedFile(name string) (confFile, bool) {
+	for _, conf := range []confFile{dhcpConfFile(), dhcp6ConfFile(), interfacesFile(), keaConfFile(), dnsmasqConfFile()} {
+		if conf.name == name {
+			return conf, true
+		}
+	}
+	return confFile{}, false
+}
+
+// ValidationError is returned when the syntax checker rejects a candidate
+// configuration. Lines holds the checker's diagnostics.
+type ValidationError struct {
+	Lines []string
+}
+
+func (e *ValidationError) Error() string {
+	return "configuration rejected by syntax check: " + strings.Join(e.Lines, "; ")
+}
+
+func loadConfFile(path string) (*dhcpconf.File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Error reading DHCP config file %s: %v", path, err)
+		return nil, fmt.Errorf("failed to read DHCP config: %w", err)
+	}
+
+	f, err := dhcpconf.Parse(content)
+	if err != nil {
+		log.Printf("Error parsing DHCP config file %s: %v", path, err)
+		return nil, fmt.Errorf("failed to parse DHCP config: %w", err)
+	}
+	return f, nil
+}
+
+// editConfFile loads a config file, applies edit to the syntax tree and
+// saves the result, all under the file's lock. Nothing is written if edit
+// fails or returns errUnchanged.
+func editConfFile(conf confFile, edit func(f *dhcpconf.File) error) error {
+	return withFileLock(conf.path, func() error {
+		f, err := loadConfFile(conf.path)
+		if err != nil {
+			return err
+		}
+		if err := edit(f); err != nil {
+			if errors.Is(err, errUnchanged) {
+				return nil
+			}
+			return err
+		}
+		return saveConfFile(conf, f)
+	})
+}
+
+func saveConfFile(conf confFile, f *dhcpconf.File) error {
+	return replaceConfFile(conf, f.Bytes())
+}
+
+// replaceConfFile stages the new content next to the real file, runs the
+// syntax checker on it, snapshots the current version and only then moves
+// the new one into place. The caller must hold the file's lock.
+func replaceConfFile(conf confFile, data []byte) error {
+	return replaceConfFiles(confWrite{conf, data})
+}
+
+// confWrite is new content for a managed file
+type confWrite struct {
+	conf confFile
+	data []byte
+}
+
+// replaceConfFiles is replaceConfFile for files that must change together.
+// Every file is staged and checked before any of them is snapshotted or
+// replaced, so a rejected file leaves all of them untouched. The current
+// content is staged as well, so if a later file cannot be moved into place
+// the ones already replaced are put back. The caller must hold the lock of
+// every file.
+func replaceConfFiles(writes ...confWrite) error {
+	staged := make([]string, 0, len(writes))
+	var backups []string
+	defer func() {
+		for _, tmpPath := range append(staged, backups...) {
+			if tmpPath != "" {
+				os.Remove(tmpPath) // no-op once committed
+			}
+		}
+	}()
+
+	for _, w := range writes {
+		tmpPath, err := stageFile(w.conf.path, w.data)
+		if tmpPath != "" {
+			staged = append(staged, tmpPath)
+		}
+		if err != nil {
+			return writeError(w.conf, err)
+		}
+		if err := checkConfig(w.conf, tmpPath); err != nil {
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				return err
+			}
+			return writeError(w.conf, err)
+		}
+	}
+	if len(writes) > 1 {
+		for _, w := range writes {
+			backup, err := stageBackup(w.conf.path)
+			backups = append(backups, backup)
+			if err != nil {
+				return writeError(w.conf, err)
+			}
+		}
+	}
+	for _, w := range writes {
+		if err := snapshotFile(w.conf); err != nil {
+			return writeError(w.conf, err)
+		}
+	}
+	for i, w := range writes {
+		if err := commitFile(staged[i], w.conf.path); err != nil {
+			return rollbackConfFiles(writes[:i], backups, writeError(w.conf, err))
+		}
+	}
+	return nil
+}
+
+// stageBackup stages the current content of path so it can be committed
+// back; "" means the file does not exist yet
+func stageBackup(path string) (string, error) {
+	current, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return stageFile(path, current)
+}
+
+// rollbackConfFiles puts back the previous content of the files of a batch
+// that were replaced before a later one failed with err
+func rollbackConfFiles(committed []confWrite, backups []string, err error) error {
+	if len(committed) == 0 {
+		return err
+	}
+	var names []string
+	for i, w := range committed {
+		var rollbackErr error
+		if backups[i] == "" {
+			rollbackErr = os.Remove(w.conf.path)
+		} else {
+			rollbackErr = commitFile(backups[i], w.conf.path)
+		}
+		if rollbackErr != nil {
+			log.Printf("ERROR: could not roll back %s: %v", w.conf.path, rollbackErr)
+			return fmt.Errorf("%w; rolling back %s failed too, so it has the new content: %v", err, w.conf.name, rollbackErr)
+		}
+		names = append(names, w.conf.name)
+	}
+	log.Printf("Rolled back %s after a failed write", strings.Join(names, ", "))
+	return fmt.Errorf("%w; %s rolled back to the previous content", err, strings.Join(names, ", "))
+}
+
+func writeError(conf confFile, err error) error {
+	log.Printf("Error writing config file %s: %v", conf.path, err)
+	return fmt.Errorf("failed to write %s: %w", conf.name, err)
+}
+
+// checkConfig runs the configured syntax checker against a staged file.
+// `{file}` in the command is replaced by the staged path, otherwise the
+// path is appended.
+func checkConfig(conf confFile, stagedPath string) error {
+	command := strings.TrimSpace(conf.checkCommand)
+	if command == "" {
+		return nil
+	}
+	if strings.Contains(command, "{file}") {
+		command = strings.ReplaceAll(command, "{file}", `"$1"`)
+	} else {
+		command += ` "$1"`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), applyTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", command, "sh", stagedPath).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() == 127 {
+		log.Printf("Syntax checker %q could not be run: %v: %s", conf.checkCommand, err, output)
+		return fmt.Errorf("failed to run syntax checker: %w", err)
+	}
+
+	lines := checkerDiagnostics(string(output), stagedPath, conf.path)
+	log.Printf("Syntax check rejected %s: %s", conf.path, strings.Join(lines, "; "))
+	return &ValidationError{Lines: lines}
+}
+
+// checkerBanner matches the version and copyright lines dhcpd prints on
+// every run
+var checkerBanner = []string{
+	"Internet Systems Consortium DHCP Server",
+	"Copyright ",
+	"All rights reserved.",
+	"For info, please visit",
+	"Config file: ",
+	"Database file: ",
+	"PID file: ",
+}
+
+// checkerDiagnostics strips the dhcpd banner from checker output and refers
+// to the real config path instead of the staging file
+func checkerDiagnostics(output, stagedPath, realPath string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		banner := false
+		for _, prefix := range checkerBanner {
+			if strings.HasPrefix(line, prefix) {
+				banner = true
+				break
+			}
+		}
+		if !banner {
+			lines = append(lines, strings.ReplaceAll(line, stagedPath, realPath))
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "syntax check failed")
+	}
+	return lines
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+)
+
+// tempConfFiles creates two managed files holding "old a" and "old b" in a
+// temp dir, with history disabled
+func tempConfFiles(t *testing.T) (string, confFile, confFile) {
+	t.Helper()
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+	config.AppConfig.HistoryDir = ""
+
+	dir := t.TempDir()
+	a := confFile{"a.conf", filepath.Join(dir, "a.conf"), ""}
+	b := confFile{"b.conf", filepath.Join(dir, "b.conf"), ""}
+	for _, conf := range []confFile{a, b} {
+		if err := os.WriteFile(conf.path, []byte("old "+strings.TrimSuffix(conf.name, ".conf")), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, a, b
+}
+
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+	}
+}
+
+// assertNoTempFiles checks that no staged or backup file was left behind
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("left behind %s", e.Name())
+		}
+	}
+}
+
+func TestReplaceConfFiles(t *testing.T) {
+	dir, a, b := tempConfFiles(t)
+
+	if err := replaceConfFiles(confWrite{a, []byte("new a")}, confWrite{b, []byte("new b")}); err != nil {
+		t.Fatalf("replaceConfFiles: %v", err)
+	}
+	assertContent(t, a.path, "new a")
+	assertContent(t, b.path, "new b")
+	assertNoTempFiles(t, dir)
+}
+
+func TestReplaceConfFilesRollsBack(t *testing.T) {
+	dir, a, b := tempConfFiles(t)
+	t.Cleanup(func() { renameFile = os.Rename })
+	renameFile = func(from, to string) error {
+		if to == b.path {
+			return errors.New("disk full")
+		}
+		return os.Rename(from, to)
+	}
+
+	err := replaceConfFiles(confWrite{a, []byte("new a")}, confWrite{b, []byte("new b")})
+	if err == nil || !strings.Contains(err.Error(), "disk full") || !strings.Contains(err.Error(), "a.conf rolled back") {
+		t.Fatalf("error = %v, want the failed rename and the rollback", err)
+	}
+	assertContent(t, a.path, "old a")
+	assertContent(t, b.path, "old b")
+	assertNoTempFiles(t, dir)
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// duidRegex matches a DUID written as colon-separated hex octets
+var duidRegex = regexp.MustCompile(`^[0-9a-fA-F]{1,2}(:[0-9a-fA-F]{1,2})+$`)
+
+// host6Params maps the models.Host6 fields to their dhcpd6.conf statements
+var host6Params = []struct {
+	keyword []string
+	field   func(h *models.Host6) *string
+	update  func(u *models.Host6Update) *string
+}{
+	{
+		[]string{"host-identifier", "option", "dhcp6.client-id"},
+		func(h *models.Host6) *string { return &h.ClientID },
+		func(u *models.Host6Update) *string { return u.ClientID },
+	},
+	{
+		[]string{"hardware", "ethernet"},
+		func(h *models.Host6) *string { return &h.HardwareEthernet },
+		func(u *models.Host6Update) *string { return u.HardwareEthernet },
+	},
+	{
+		[]string{"fixed-address6"},
+		func(h *models.Host6) *string { return &h.FixedAddress6 },
+		func(u *models.Host6Update) *string { return u.FixedAddress6 },
+	},
+	{
+		[]string{"fixed-prefix6"},
+		func(h *models.Host6) *string { return &h.FixedPrefix6 },
+		func(u *models.Host6Update) *string { return u.FixedPrefix6 },
+	},
+}
+
+func editDhcp6Conf(edit func(f *dhcpconf.File) error) error {
+	return editConfFile(dhcp6ConfFile(), edit)
+}
+
+func ListHosts6() ([]models.Host6, error) {
+	f, err := loadConfFile(config.AppConfig.Dhcp6ConfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []models.Host6
+	dhcpconf.Walk(f.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+		if b, ok := n.(*dhcpconf.Block); ok && b.Keyword() == "host" {
+			hosts = append(hosts, host6FromBlock(b))
+			return false
+		}
+		return true
+	})
+	return hosts, nil
+}
+
+func host6FromBlock(b *dhcpconf.Block) models.Host6 {
+	host := models.Host6{Name: b.Name()}
+	for _, p := range host6Params {
+		*p.field(&host) = getParam(b, p.keyword...)
+	}
+	return host
+}
+
+// validateHost6 checks the identifier and the IPv6 address and prefix, and
+// normalizes the MAC address in place
+func validateHost6(host *models.Host6) error {
+	if host.ClientID == "" && host.HardwareEthernet == "" {
+		return &FieldError{"client_id", "either client_id or hardware_ethernet is required"}
+	}
+	if host.ClientID != "" && !duidRegex.MatchString(host.ClientID) {
+		return &FieldError{"client_id", fmt.Sprintf("invalid DUID %q: expected colon-separated hex octets", host.ClientID)}
+	}
+	if host.HardwareEthernet != "" {
+		mac, err := normalizeMAC("hardware_ethernet", host.HardwareEthernet)
+		if err != nil {
+			return err
+		}
+		host.HardwareEthernet = mac
+	}
+	if host.FixedAddress6 != "" {
+		if ip := net.ParseIP(host.FixedAddress6); ip == nil || ip.To4() != nil {
+			return &FieldError{"fixed_address6", fmt.Sprintf("%q is not an IPv6 address", host.FixedAddress6)}
+		}
+	}
+	if host.FixedPrefix6 != "" {
+		ip, prefix, err := net.ParseCIDR(host.FixedPrefix6)
+		if err != nil || ip.To4() != nil {
+			return &FieldError{"fixed_prefix6", fmt.Sprintf("%q is not an IPv6 prefix", host.FixedPrefix6)}
+		}
+		if !ip.Equal(prefix.IP) {
+			return &FieldError{"fixed_prefix6", fmt.Sprintf("prefix %q has host bits set", host.FixedPrefix6)}
+		}
+	}
+	return nil
+}
+
+func AddHost6(host models.Host6) error {
+	if err := validateIdentifier("name", host.Name); err != nil {
+		return err
+	}
+	if err := validateHost6(&host); err != nil {
+		return err
+	}
+	return editDhcp6Conf(func(f *dhcpconf.File) error {
+		if findHost(f, host.Name) != nil {
+			return fmt.Errorf("host %s already exists", host.Name)
+		}
+
+		b := dhcpconf.NewBlock("host", host.Name)
+		for _, p := range host6Params {
+			if err := setParam(b, p.keyword, *p.field(&host)); err != nil {
+				return err
+			}
+		}
+		f.Append(b)
+		return nil
+	})
+}
+
+func UpdateHost6(name string, updates models.Host6Update) error {
+	return editDhcp6Conf(func(f *dhcpconf.File) error {
+		b := findHost(f, name)
+		if b == nil {
+			log.Printf("IPv6 host %s not found for update.", name)
+			return fmt.Errorf("host %s %w", name, ErrNotFound)
+		}
+
+		host := host6FromBlock(b)
+		if updates.Name != nil && *updates.Name != "" && *updates.Name != name {
+			if err := validateIdentifier("name", *updates.Name); err != nil {
+				return err
+			}
+			if findHost(f, *updates.Name) != nil {
+				return fmt.Errorf("host %s already exists", *updates.Name)
+			}
+			b.Args[1] = *updates.Name
+		}
+		for _, p := range host6Params {
+			if value := p.update(&updates); value != nil {
+				*p.field(&host) = *value
+			}
+		}
+		if err := validateHost6(&host); err != nil {
+			return err
+		}
+
+		for _, p := range host6Params {
+			if p.update(&updates) != nil {
+				if err := setParam(b, p.keyword, *p.field(&host)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteHost6 removes an IPv6 host and reports whether it was there
+func DeleteHost6(name string) (bool, error) {
+	deleted := false
+	err := editDhcp6Conf(func(f *dhcpconf.File) error {
+		b := findHost(f, name)
+		if b == nil {
+			log.Printf("IPv6 host %s not found for deletion.", name)
+			return errUnchanged // idempotent delete
+		}
+		f.Remove(b)
+		deleted = true
+		return nil
+	})
+	return deleted && err == nil, err
+}
@@ -1,177 +1,649 @@
 package services
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"regexp"
+	"math"
+	"net"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
 	"github.com/0xPixelNinja/dhcp-rest-api/models"
 )
 
-func ListHosts() ([]models.Host, error) {
-	content, err := os.ReadFile(config.AppConfig.DhcpConfPath)
+// hostParams maps the models.Host fields to their dhcpd statements, in the
+// order new host blocks are written
+var hostParams = []struct {
+	keyword []string
+	field   func(h *models.Host) *string
+	update  func(u *models.HostUpdate) *string
+}{
+	{
+		[]string{"hardware", "ethernet"},
+		func(h *models.Host) *string { return &h.HardwareEthernet },
+		func(u *models.HostUpdate) *string { return u.HardwareEthernet },
+	},
+	{
+		[]string{"option", "routers"},
+		func(h *models.Host) *string { return &h.OptionRouters },
+		func(u *models.HostUpdate) *string { return u.OptionRouters },
+	},
+	{
+		[]string{"option", "subnet-mask"},
+		func(h *models.Host) *string { return &h.OptionSubnetMask },
+		func(u *models.HostUpdate) *string { return u.OptionSubnetMask },
+	},
+	{
+		[]string{"fixed-address"},
+		func(h *models.Host) *string { return &h.FixedAddress },
+		func(u *models.HostUpdate) *string { return u.FixedAddress },
+	},
+	{
+		[]string{"option", "domain-name-servers"},
+		func(h *models.Host) *string { return &h.OptionDomainNameServers },
+		func(u *models.HostUpdate) *string { return u.OptionDomainNameServers },
+	},
+}
+
+// textParams are statements whose value is a quoted string in dhcpd.conf.
+// Their values are quoted on write and unquoted on read, so API clients
+// never deal with dhcpd string syntax.
+var textParams = map[string]bool{
+	"option host-name":   true,
+	"option domain-name": true,
+	"option root-path":   true,
+	"ddns-hostname":      true,
+	"ddns-domainname":    true,
+	"server-name":        true,
+}
+
+// ErrNotFound is wrapped by errors about missing hosts, leases and other
+// objects so handlers can answer with 404
+var ErrNotFound = errors.New("not found")
+
+// errUnchanged is returned by an edit function to skip writing the file
+var errUnchanged = errors.New("configuration unchanged")
+
+func loadDhcpConf() (*dhcpconf.File, error) {
+	return loadConfFile(config.AppConfig.DhcpConfPath)
+}
+
+// editDhcpConf loads dhcpd.conf, applies edit to the syntax tree and writes
+// the result back. Nothing is written if edit fails or returns errUnchanged.
+func editDhcpConf(edit func(f *dhcpconf.File) error) error {
+	return editConfFile(dhcpConfFile(), edit)
+}
+
+// ListHosts returns one page of the host reservations matching query,
+// together with the number of matches before paging
+func ListHosts(query models.HostQuery) ([]models.Host, int, error) {
+	f, err := loadDhcpConf()
 	if err != nil {
-		log.Printf("Error reading DHCP config file: %v", err)
-		return nil, fmt.Errorf("failed to read DHCP config: %w", err)
+		return nil, 0, err
 	}
+	return queryHosts(listHosts(f), query)
+}
 
-	var hosts []models.Host
-	hostBlockRegex := regexp.MustCompile(`(?s)host\s+([^\s]+)\s*\{([^}]+)\}`)
-	hardwareEthernetRegex := regexp.MustCompile(`hardware ethernet\s+([^;]+);`)
-	optionRoutersRegex := regexp.MustCompile(`option routers\s+([^;]+);`)
-	optionSubnetMaskRegex := regexp.MustCompile(`option subnet-mask\s+([^;]+);`)
-	fixedAddressRegex := regexp.MustCompile(`fixed-address\s+([^;]+);`)
-	optionDomainNameServersRegex := regexp.MustCompile(`option domain-name-servers\s+([^;]+);`)
-
-	matches := hostBlockRegex.FindAllStringSubmatch(string(content), -1)
-	for _, match := range matches {
-		if len(match) < 3 {
+func filterHosts(hosts []models.Host, filter models.HostFilter) ([]models.Host, error) {
+	var subnet *net.IPNet
+	if filter.Subnet != "" {
+		_, n, err := net.ParseCIDR(filter.Subnet)
+		if err != nil {
+			return nil, &FieldError{"subnet", fmt.Sprintf("invalid subnet filter %q: expected CIDR notation", filter.Subnet)}
+		}
+		subnet = n
+	}
+	mac := filter.MAC
+	if mac != "" {
+		var err error
+		if mac, err = normalizeMAC("mac", mac); err != nil {
+			return nil, err
+		}
+	}
+	name := strings.ToLower(filter.Name)
+	glob := strings.ContainsAny(name, "*?[")
+	if glob {
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, &FieldError{"name", fmt.Sprintf("invalid pattern %q", filter.Name)}
+		}
+	}
+
+	matched := []models.Host{}
+	for _, host := range hosts {
+		if mac != "" && !strings.EqualFold(host.HardwareEthernet, mac) {
 			continue
 		}
-		hostName := match[1]
-		blockContent := match[2]
+		addrs := splitAddresses(host.FixedAddress)
+		if filter.IP != "" && !slices.Contains(addrs, filter.IP) {
+			continue
+		}
+		if subnet != nil && !slices.ContainsFunc(addrs, func(a string) bool { return subnet.Contains(net.ParseIP(a)) }) {
+			continue
+		}
+		if glob {
+			if ok, _ := path.Match(name, strings.ToLower(host.Name)); !ok {
+				continue
+			}
+		} else if name != "" && !strings.Contains(strings.ToLower(host.Name), name) {
+			continue
+		}
+		matched = append(matched, host)
+	}
+	return matched, nil
+}
 
-		host := models.Host{Name: hostName}
+// sortHosts orders hosts by name, ip or mac, descending with a leading -.
+// Addresses compare numerically; hosts without one sort last.
+func sortHosts(hosts []models.Host, by string) {
+	desc := strings.HasPrefix(by, "-")
+	by = strings.TrimPrefix(by, "-")
 
-		if m := hardwareEthernetRegex.FindStringSubmatch(blockContent); len(m) > 1 {
-			host.HardwareEthernet = strings.TrimSpace(m[1])
+	addrKey := func(h models.Host) uint64 {
+		for _, a := range splitAddresses(h.FixedAddress) {
+			if n, err := ipv4ToUint(a); err == nil {
+				return uint64(n)
+			}
 		}
-		if m := optionRoutersRegex.FindStringSubmatch(blockContent); len(m) > 1 {
-			host.OptionRouters = strings.TrimSpace(m[1])
+		return math.MaxUint64
+	}
+	slices.SortStableFunc(hosts, func(a, b models.Host) int {
+		var c int
+		switch by {
+		case "ip":
+			c = cmp.Compare(addrKey(a), addrKey(b))
+		case "mac":
+			c = strings.Compare(strings.ToLower(a.HardwareEthernet), strings.ToLower(b.HardwareEthernet))
+		case "name":
+			c = strings.Compare(a.Name, b.Name)
 		}
-		if m := optionSubnetMaskRegex.FindStringSubmatch(blockContent); len(m) > 1 {
-			host.OptionSubnetMask = strings.TrimSpace(m[1])
+		if desc {
+			c = -c
 		}
-		if m := fixedAddressRegex.FindStringSubmatch(blockContent); len(m) > 1 {
-			host.FixedAddress = strings.TrimSpace(m[1])
+		return c
+	})
+}
+
+// GetHost returns a single host reservation by name
+func GetHost(name string) (models.Host, error) {
+	f, err := loadDhcpConf()
+	if err != nil {
+		return models.Host{}, err
+	}
+	return findHostIn(listHosts(f), name)
+}
+
+func listHosts(f *dhcpconf.File) []models.Host {
+	var hosts []models.Host
+	dhcpconf.Walk(f.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+		if b, ok := n.(*dhcpconf.Block); ok && b.Keyword() == "host" {
+			host := hostFromBlock(b)
+			host.Group, host.SharedNetwork = hostLocation(parents)
+			hosts = append(hosts, host)
+			return false
 		}
-		if m := optionDomainNameServersRegex.FindStringSubmatch(blockContent); len(m) > 1 {
-			host.OptionDomainNameServers = strings.TrimSpace(m[1])
+		return true
+	})
+	return hosts
+}
+
+// hostLocation returns the names of the innermost group and shared-network
+// enclosing a host
+func hostLocation(parents []*dhcpconf.Block) (group, sharedNetwork string) {
+	for _, p := range parents {
+		switch p.Keyword() {
+		case "group":
+			group = p.Name()
+		case "shared-network":
+			sharedNetwork = p.Name()
 		}
-		hosts = append(hosts, host)
 	}
-	return hosts, nil
+	return group, sharedNetwork
 }
 
-func AddHost(host models.Host) error {
-	hostBlock := fmt.Sprintf("\nhost %s {\n    hardware ethernet %s;\n    option routers %s;\n    option subnet-mask %s;\n    fixed-address %s;\n    option domain-name-servers %s;\n}\n",
-		host.Name, host.HardwareEthernet, host.OptionRouters, host.OptionSubnetMask, host.FixedAddress, host.OptionDomainNameServers)
+// findHost returns the host declaration with the given name, wherever it
+// is nested, or nil
+func findHost(f *dhcpconf.File, name string) *dhcpconf.Block {
+	var found *dhcpconf.Block
+	dhcpconf.Walk(f.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+		if found != nil {
+			return false
+		}
+		if b, ok := n.(*dhcpconf.Block); ok && b.Keyword() == "host" {
+			if b.Name() == name {
+				found = b
+			}
+			return false
+		}
+		return true
+	})
+	return found
+}
 
-	f, err := os.OpenFile(config.AppConfig.DhcpConfPath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Printf("Error opening DHCP config file for append: %v", err)
-		return fmt.Errorf("failed to open DHCP config for append: %w", err)
+func hostFromBlock(b *dhcpconf.Block) models.Host {
+	host := models.Host{Name: b.Name()}
+	for _, p := range hostParams {
+		*p.field(&host) = getParam(b, p.keyword...)
 	}
-	defer f.Close()
+	host.Options = hostOptions(b)
+	host.Boot = hostBoot(b)
+	return host
+}
 
-	if _, err := f.WriteString(hostBlock); err != nil {
-		log.Printf("Error writing to DHCP config file: %v", err)
-		return fmt.Errorf("failed to write to DHCP config: %w", err)
+// isHostField reports whether a statement is represented by one of the
+// dedicated models.Host fields rather than by Options
+func isHostField(s *dhcpconf.Statement) bool {
+	if isBootParam(s) {
+		return true
 	}
-	return nil
+	for _, p := range hostParams {
+		if _, ok := s.Match(p.keyword...); ok {
+			return true
+		}
+	}
+	return false
 }
 
-func UpdateHost(name string, updates models.HostUpdate) error {
-	content, err := os.ReadFile(config.AppConfig.DhcpConfPath)
-	if err != nil {
-		log.Printf("Error reading DHCP config file: %v", err)
-		return fmt.Errorf("failed to read DHCP config for update: %w", err)
+// optionName splits a statement into its option name, e.g. "option
+// host-name" or "ddns-hostname", and the remaining value tokens
+func optionName(args []string) (string, []string) {
+	n := 1
+	if (args[0] == "option" || args[0] == "hardware") && len(args) > 1 {
+		n = 2
 	}
+	return strings.Join(args[:n], " "), args[n:]
+}
 
-	hostBlockRegex := regexp.MustCompile(fmt.Sprintf(`(?s)(host\s+%s\s*\{)([^}]+)(\})`, regexp.QuoteMeta(name)))
+// hostOptions collects every simple statement of a host that has no
+// dedicated field, in file order
+func hostOptions(b *dhcpconf.Block) models.Options {
+	var opts models.Options
+	for _, n := range b.Body {
+		s, ok := n.(*dhcpconf.Statement)
+		if !ok || len(s.Args) == 0 || isHostField(s) {
+			continue
+		}
+		name, args := optionName(s.Args)
+		value := dhcpconf.Join(args)
+		if textParams[name] {
+			value = dhcpconf.Unquote(value)
+		}
+		opts.Set(name, value)
+	}
+	return opts
+}
 
-	match := hostBlockRegex.FindStringSubmatchIndex(string(content))
-	if match == nil {
-		log.Printf("Host %s not found for update.", name)
-		return fmt.Errorf("host %s not found", name)
+// setHostOption sets, adds or (with an empty value) removes an extra host
+// statement
+func setHostOption(b *dhcpconf.Block, name, value string) error {
+	keyword, err := dhcpconf.Fields(name)
+	if err != nil || len(keyword) == 0 {
+		return fmt.Errorf("invalid option name %q", name)
+	}
+	for _, k := range keyword {
+		if k == "," || strings.HasPrefix(k, `"`) {
+			return fmt.Errorf("invalid option name %q", name)
+		}
+	}
+	if isHostField(dhcpconf.NewStatement(keyword...)) {
+		return fmt.Errorf("option %q must be set through its dedicated field", name)
 	}
 
-	// Extract current fields from the block
-	blockContent := string(content[match[4]:match[5]])
-	currentHost := models.Host{Name: name}
+	name = strings.Join(keyword, " ")
+	if textParams[name] && value != "" {
+		value = dhcpconf.Quote(value)
+	}
+	return setParam(b, keyword, value)
+}
+
+// scope is somewhere parameters can be declared: a block, or the top level
+// of the file via globalScope
+type scope interface {
+	Find(prefix ...string) *dhcpconf.Statement
+	Append(nodes ...dhcpconf.Node)
+	Remove(target dhcpconf.Node) bool
+}
+
+// globalScope exposes the top-level statements of dhcpd.conf. New
+// statements are placed after the existing ones, ahead of any declarations.
+type globalScope struct {
+	*dhcpconf.File
+}
 
-	if m := regexp.MustCompile(`hardware ethernet\s+([^;]+);`).FindStringSubmatch(blockContent); len(m) > 1 {
-		currentHost.HardwareEthernet = strings.TrimSpace(m[1])
+func (g globalScope) Append(nodes ...dhcpconf.Node) {
+	var last dhcpconf.Node
+	for _, n := range g.Body {
+		if s, ok := n.(*dhcpconf.Statement); ok {
+			last = s
+		}
+	}
+	for _, n := range nodes {
+		if last == nil {
+			g.Prepend(n)
+		} else {
+			g.InsertAfter(last, n)
+		}
+		last = n
 	}
-	if m := regexp.MustCompile(`option routers\s+([^;]+);`).FindStringSubmatch(blockContent); len(m) > 1 {
-		currentHost.OptionRouters = strings.TrimSpace(m[1])
+}
+
+// getParam returns the value of the first statement starting with keyword
+func getParam(b scope, keyword ...string) string {
+	s := b.Find(keyword...)
+	if s == nil {
+		return ""
 	}
-	if m := regexp.MustCompile(`option subnet-mask\s+([^;]+);`).FindStringSubmatch(blockContent); len(m) > 1 {
-		currentHost.OptionSubnetMask = strings.TrimSpace(m[1])
+	args, _ := s.Match(keyword...)
+	return dhcpconf.Join(args)
+}
+
+func getIntParam(b scope, keyword ...string) int {
+	n, _ := strconv.Atoi(getParam(b, keyword...))
+	return n
+}
+
+// setParam replaces the value of the first statement starting with keyword,
+// appending a new statement if there is none. An empty value removes it.
+func setParam(b scope, keyword []string, value string) error {
+	existing := b.Find(keyword...)
+	if value == "" {
+		if existing != nil {
+			b.Remove(existing)
+		}
+		return nil
 	}
-	if m := regexp.MustCompile(`fixed-address\s+([^;]+);`).FindStringSubmatch(blockContent); len(m) > 1 {
-		currentHost.FixedAddress = strings.TrimSpace(m[1])
+
+	args, err := dhcpconf.Fields(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", dhcpconf.Join(keyword), err)
 	}
-	if m := regexp.MustCompile(`option domain-name-servers\s+([^;]+);`).FindStringSubmatch(blockContent); len(m) > 1 {
-		currentHost.OptionDomainNameServers = strings.TrimSpace(m[1])
+	args = append(append([]string(nil), keyword...), args...)
+	if existing != nil {
+		existing.Args = args
+	} else {
+		b.Append(dhcpconf.NewStatement(args...))
 	}
+	return nil
+}
 
-	// Apply updates
-	updatedName := name
-	if updates.Name != nil && *updates.Name != "" {
-		updatedName = *updates.Name
-		currentHost.Name = *updates.Name
+// insertDeclaration adds a top-level subnet, shared-network or group block
+// after the last existing one, keeping them together ahead of the host
+// declarations
+func insertDeclaration(f *dhcpconf.File, b *dhcpconf.Block) {
+	var last dhcpconf.Node
+	for _, n := range f.Body {
+		if blk, ok := n.(*dhcpconf.Block); ok {
+			switch blk.Keyword() {
+			case "subnet", "shared-network", "group":
+				last = blk
+			}
+		}
 	}
-	if updates.HardwareEthernet != nil {
-		currentHost.HardwareEthernet = *updates.HardwareEthernet
+	f.InsertAfter(last, b)
+}
+
+// setIntParam sets a numeric statement such as default-lease-time; zero
+// removes it
+func setIntParam(b scope, keyword []string, value int) error {
+	if value < 0 {
+		return fmt.Errorf("invalid value for %s: must not be negative", dhcpconf.Join(keyword))
 	}
-	if updates.OptionRouters != nil {
-		currentHost.OptionRouters = *updates.OptionRouters
+	if value == 0 {
+		return setParam(b, keyword, "")
 	}
-	if updates.OptionSubnetMask != nil {
-		currentHost.OptionSubnetMask = *updates.OptionSubnetMask
+	return setParam(b, keyword, strconv.Itoa(value))
+}
+
+// setStringParam sets a statement whose value is a quoted string
+func setStringParam(b scope, keyword []string, value string) error {
+	if value == "" {
+		return setParam(b, keyword, "")
 	}
-	if updates.FixedAddress != nil {
-		currentHost.FixedAddress = *updates.FixedAddress
+	return setParam(b, keyword, dhcpconf.Quote(value))
+}
+
+// setFlag adds or removes a bare statement such as `authoritative;`
+func setFlag(b scope, on bool, keyword ...string) {
+	existing := b.Find(keyword...)
+	if on && existing == nil {
+		b.Append(dhcpconf.NewStatement(keyword...))
 	}
-	if updates.OptionDomainNameServers != nil {
-		currentHost.OptionDomainNameServers = *updates.OptionDomainNameServers
+	if !on && existing != nil {
+		b.Remove(existing)
 	}
+}
 
-	// Build the new host block
-	newHostBlockContent := fmt.Sprintf("\n    hardware ethernet %s;\n    option routers %s;\n    option subnet-mask %s;\n    fixed-address %s;\n    option domain-name-servers %s;\n",
-		currentHost.HardwareEthernet, currentHost.OptionRouters, currentHost.OptionSubnetMask, currentHost.FixedAddress, currentHost.OptionDomainNameServers)
+// AddHost adds a host reservation and returns it as stored, with any
+// allocated address and subnet defaults filled in
+func AddHost(host models.Host) (added models.Host, err error) {
+	err = editDhcpConf(func(f *dhcpconf.File) error {
+		if err := addHost(f, &host); err != nil {
+			return err
+		}
+		added, err = findHostIn(listHosts(f), host.Name)
+		return err
+	})
+	return added, err
+}
 
-	newHostBlock := fmt.Sprintf("host %s { %s}", updatedName, newHostBlockContent)
+// addHost validates host, allocates its address if requested and appends
+// it to f. host is updated with the values actually written.
+func addHost(f *dhcpconf.File, host *models.Host) error {
+	if host.FixedAddress == autoAddress || host.Subnet != "" {
+		if host.FixedAddress != "" && host.FixedAddress != autoAddress {
+			return &FieldError{"subnet", "cannot be combined with a literal fixed_address"}
+		}
+		if err := allocateAddress(f, host); err != nil {
+			return err
+		}
+		host.Subnet = ""
+	}
+	if host.FixedAddress == "" {
+		return &FieldError{"fixed_address", "required unless subnet is given"}
+	}
+	if err := validateHost(host); err != nil {
+		return err
+	}
+	if host.OptionRouters == "" || host.OptionSubnetMask == "" || host.OptionDomainNameServers == "" {
+		// Best effort: a host outside every declared subnet keeps its gaps
+		_ = fillFromSubnet(f, host)
+	}
+	if err := checkHostConflicts(f, *host, nil); err != nil {
+		return err
+	}
 
-	// Replace the entire host block
-	newFileContent := string(content[:match[0]]) + newHostBlock + string(content[match[1]:])
+	b := dhcpconf.NewBlock("host", host.Name)
+	for _, p := range hostParams {
+		if err := setParam(b, p.keyword, *p.field(host)); err != nil {
+			return err
+		}
+	}
+	for _, opt := range host.Options {
+		if err := setHostOption(b, opt.Name, opt.Value); err != nil {
+			return &FieldError{"options." + opt.Name, err.Error()}
+		}
+	}
+	if host.Boot != nil {
+		if err := setHostBoot(b, *host.Boot); err != nil {
+			return err
+		}
+	}
 
-	if err := os.WriteFile(config.AppConfig.DhcpConfPath, []byte(newFileContent), 0644); err != nil {
-		log.Printf("Error writing updated DHCP config file: %v", err)
-		return fmt.Errorf("failed to write updated DHCP config: %w", err)
+	if host.Group != "" {
+		group := findGroup(f, host.Group)
+		if group == nil {
+			return &FieldError{"group", fmt.Sprintf("group %s is not declared", host.Group)}
+		}
+		group.Append(b)
+		return nil
 	}
+	f.Append(b)
 	return nil
 }
 
-func DeleteHost(name string) error {
-	content, err := os.ReadFile(config.AppConfig.DhcpConfPath)
-	if err != nil {
-		log.Printf("Error reading DHCP config file: %v", err)
-		return fmt.Errorf("failed to read DHCP config for delete: %w", err)
+// UpdateHost applies updates to a host if cond holds for its current state
+// and returns the host as stored before and after the change
+func UpdateHost(name string, updates models.HostUpdate, cond Precondition) (before, after models.Host, err error) {
+	err = editDhcpConf(func(f *dhcpconf.File) error {
+		hosts := listHosts(f)
+		if err := cond.check(hostETagIn(hosts, name)); err != nil {
+			return err
+		}
+		before, _ = findHostIn(hosts, name)
+		if err := updateHost(f, name, updates); err != nil {
+			return err
+		}
+		after, err = findHostIn(listHosts(f), renamedHost(name, updates))
+		return err
+	})
+	return before, after, err
+}
+
+// renamedHost returns the name of a host after updates are applied
+func renamedHost(name string, updates models.HostUpdate) string {
+	if updates.Name != nil && *updates.Name != "" {
+		return *updates.Name
 	}
+	return name
+}
 
-	hostBlockRegex := regexp.MustCompile(fmt.Sprintf(`(?s)\s*host\s+%s\s*\{[^}]*\}\s*`, regexp.QuoteMeta(name)))
+func updateHost(f *dhcpconf.File, name string, updates models.HostUpdate) error {
+	if err := validateHostUpdate(&updates); err != nil {
+		return err
+	}
+	b := findHost(f, name)
+	if b == nil {
+		log.Printf("Host %s not found for update.", name)
+		return fmt.Errorf("host %s %w", name, ErrNotFound)
+	}
 
-	if !hostBlockRegex.Match(content) {
-		log.Printf("Host %s not found for deletion.", name)
-		return nil // idempotent delete
+	if updates.Name != nil && *updates.Name != "" {
+		b.Args[1] = *updates.Name
 	}
 
-	newContent := hostBlockRegex.ReplaceAll(content, []byte{})
+	for _, p := range hostParams {
+		value := p.update(&updates)
+		if value == nil {
+			continue
+		}
+		if err := setParam(b, p.keyword, *value); err != nil {
+			return err
+		}
+	}
+	for _, opt := range updates.Options {
+		if err := setHostOption(b, opt.Name, opt.Value); err != nil {
+			return &FieldError{"options." + opt.Name, err.Error()}
+		}
+	}
+	if updates.Boot != nil {
+		if err := setHostBoot(b, *updates.Boot); err != nil {
+			return err
+		}
+	}
 
-	// Clean up whitespace
-	newContent = []byte(strings.TrimSpace(string(newContent)))
-	if len(newContent) > 0 {
-		newContent = append(newContent, '\n')
+	if err := checkHostConflicts(f, hostFromBlock(b), b); err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(config.AppConfig.DhcpConfPath, newContent, 0644); err != nil {
-		log.Printf("Error writing updated DHCP config file after deletion: %v", err)
-		return fmt.Errorf("failed to write DHCP config after delete: %w", err)
+	if updates.Group != nil {
+		return moveHost(f, b, *updates.Group)
+	}
+	return nil
+}
+
+// ConflictError reports that a host would share its name, MAC or fixed
+// address with an existing reservation
+type ConflictError struct {
+	Field string
+	Value string
+	Host  models.Host
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s is already used by host %s", e.Field, e.Value, e.Host.Name)
+}
+
+// checkHostConflicts applies hostConflictIn to every other host declaration
+// in f. self is the block being updated, if any, and is skipped.
+func checkHostConflicts(f *dhcpconf.File, host models.Host, self *dhcpconf.Block) error {
+	var others []models.Host
+	dhcpconf.Walk(f.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+		b, ok := n.(*dhcpconf.Block)
+		if !ok || b.Keyword() != "host" {
+			return ok
+		}
+		if b != self {
+			other := hostFromBlock(b)
+			other.Group, other.SharedNetwork = hostLocation(parents)
+			others = append(others, other)
+		}
+		return false
+	})
+	return hostConflictIn(others, host, "")
+}
+
+// sharedAddress returns the first address present in both fixed-address
+// lists, or ""
+func sharedAddress(a, b string) string {
+	for _, x := range splitAddresses(a) {
+		if slices.Contains(splitAddresses(b), x) {
+			return x
+		}
+	}
+	return ""
+}
+
+// moveHost relocates a host declaration into the named group, or to the
+// top level when group is empty
+func moveHost(f *dhcpconf.File, b *dhcpconf.Block, group string) error {
+	if group == "" {
+		if slices.Contains(f.Body, dhcpconf.Node(b)) {
+			return nil
+		}
+		f.Remove(b)
+		dhcpconf.Reset(b)
+		f.Append(b)
+		return nil
+	}
+
+	target := findGroup(f, group)
+	if target == nil {
+		return &FieldError{"group", fmt.Sprintf("group %s is not declared", group)}
+	}
+	if slices.Contains(target.Body, dhcpconf.Node(b)) {
+		return nil
+	}
+	f.Remove(b)
+	dhcpconf.Reset(b)
+	target.Append(b)
+	return nil
+}
+
+// DeleteHost removes a host if cond holds for its current state and
+// returns the host as it was stored, or a zero host if there was none
+func DeleteHost(name string, cond Precondition) (deleted models.Host, err error) {
+	err = editDhcpConf(func(f *dhcpconf.File) error {
+		hosts := listHosts(f)
+		if err := cond.check(hostETagIn(hosts, name)); err != nil {
+			return err
+		}
+		deleted, _ = findHostIn(hosts, name)
+		return deleteHost(f, name)
+	})
+	return deleted, err
+}
+
+func deleteHost(f *dhcpconf.File, name string) error {
+	b := findHost(f, name)
+	if b == nil {
+		log.Printf("Host %s not found for deletion.", name)
+		return errUnchanged // idempotent delete
 	}
+	f.Remove(b)
 	return nil
 }
@@ -0,0 +1,584 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// dnsmasqConfFile is the conf.d file holding the API's dnsmasq settings
+func dnsmasqConfFile() confFile {
+	return confFile{"dnsmasq.conf", config.AppConfig.DnsmasqConfPath, config.AppConfig.CheckCommand}
+}
+
+// dnsmasqBackend manages `dhcp-host=` reservations and `interface=` lines
+// in a dnsmasq conf.d file. Per-host options and boot settings are
+// attached through a `set:` tag on the dhcp-host line. Lines the API does
+// not recognise are left alone.
+type dnsmasqBackend struct{}
+
+// dnsmasqConfig is the conf.d file as a list of lines
+type dnsmasqConfig struct {
+	lines []string
+}
+
+// dnsmasqHost is a dhcp-host line and the tagged dhcp-option and
+// dhcp-boot lines belonging to it. extra holds dhcp-host fields the API
+// does not model, such as lease times, which are kept on rewrite.
+type dnsmasqHost struct {
+	line    int
+	related []int
+	tag     string
+	extra   []string
+	host    models.Host
+}
+
+var (
+	dnsmasqLeaseTimeRegex  = regexp.MustCompile(`^(\d+[smhdw]?|infinite)$`)
+	dnsmasqOptionNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// dnsmasqHostOptions maps dnsmasq option names to the dedicated
+// models.Host fields
+var dnsmasqHostOptions = []struct {
+	name  string
+	field func(h *models.Host) *string
+}{
+	{"router", func(h *models.Host) *string { return &h.OptionRouters }},
+	{"netmask", func(h *models.Host) *string { return &h.OptionSubnetMask }},
+	{"dns-server", func(h *models.Host) *string { return &h.OptionDomainNameServers }},
+}
+
+// dnsmasqBootOptions maps dnsmasq option names to models.HostBoot fields
+var dnsmasqBootOptions = []struct {
+	name  string
+	field func(b *models.HostBoot) *string
+}{
+	{"tftp-server", func(b *models.HostBoot) *string { return &b.TFTPServerName }},
+	{"bootfile-name", func(b *models.HostBoot) *string { return &b.BootfileName }},
+}
+
+// loadDnsmasqConfig reads the conf.d file. A missing file is empty, it is
+// created on the first write.
+func loadDnsmasqConfig() (*dnsmasqConfig, error) {
+	path := config.AppConfig.DnsmasqConfPath
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &dnsmasqConfig{}, nil
+		}
+		log.Printf("Error reading dnsmasq config file %s: %v", path, err)
+		return nil, fmt.Errorf("failed to read dnsmasq config: %w", err)
+	}
+	defer file.Close()
+
+	c := &dnsmasqConfig{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		c.lines = append(c.lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error scanning dnsmasq config file %s: %v", path, err)
+		return nil, fmt.Errorf("failed to read dnsmasq config: %w", err)
+	}
+	return c, nil
+}
+
+func (c *dnsmasqConfig) bytes() []byte {
+	if len(c.lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(c.lines, "\n") + "\n")
+}
+
+// editDnsmasqConfig is editConfFile for the dnsmasq conf.d file
+func editDnsmasqConfig(edit func(c *dnsmasqConfig) error) error {
+	conf := dnsmasqConfFile()
+	return withFileLock(conf.path, func() error {
+		c, err := loadDnsmasqConfig()
+		if err != nil {
+			return err
+		}
+		if err := edit(c); err != nil {
+			if errors.Is(err, errUnchanged) {
+				return nil
+			}
+			return err
+		}
+		return replaceConfFile(conf, c.bytes())
+	})
+}
+
+// directive splits a `key=value` line; comments and blank lines have no key
+func directive(line string) (key, value string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	key, value, _ = strings.Cut(line, "=")
+	return strings.TrimSpace(key), strings.TrimSpace(value)
+}
+
+func splitFields(value string) []string {
+	fields := strings.Split(value, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
+
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+}
+
+func (c *dnsmasqConfig) hosts() []dnsmasqHost {
+	var hosts []dnsmasqHost
+	for i, line := range c.lines {
+		key, value := directive(line)
+		if key != "dhcp-host" {
+			continue
+		}
+		h := dnsmasqHost{line: i}
+		for _, field := range splitFields(value) {
+			switch {
+			case strings.HasPrefix(field, "set:") && h.tag == "":
+				h.tag = strings.TrimPrefix(field, "set:")
+			case h.host.HardwareEthernet == "" && !strings.Contains(field, "*") && isMAC(field):
+				h.host.HardwareEthernet, _ = normalizeMAC("hardware_ethernet", field)
+			case h.host.FixedAddress == "" && isIPv4(field):
+				h.host.FixedAddress = field
+			case h.host.Name == "" && field != "" && field != "ignore" && !strings.Contains(field, ":") &&
+				!strings.HasPrefix(field, "[") && !dnsmasqLeaseTimeRegex.MatchString(field):
+				h.host.Name = field
+			default:
+				h.extra = append(h.extra, field)
+			}
+		}
+		if h.tag != "" {
+			c.tagged(&h)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+func isMAC(s string) bool {
+	hw, err := net.ParseMAC(s)
+	return err == nil && len(hw) == 6
+}
+
+// tagged collects the dhcp-option and dhcp-boot lines for h's tag
+func (c *dnsmasqConfig) tagged(h *dnsmasqHost) {
+	var boot models.HostBoot
+	for i, line := range c.lines {
+		key, value := directive(line)
+		fields := splitFields(value)
+		if len(fields) < 2 || fields[0] != "tag:"+h.tag {
+			continue
+		}
+		switch key {
+		case "dhcp-option":
+			name, ok := strings.CutPrefix(fields[1], "option:")
+			if !ok || len(fields) < 3 {
+				continue
+			}
+			data := strings.Join(fields[2:], ",")
+			h.related = append(h.related, i)
+			if field := dnsmasqHostField(name); field != nil {
+				if list, err := normalizeIPv4List(name, data); err == nil && name != "netmask" {
+					data = list
+				}
+				*field(&h.host) = data
+				continue
+			}
+			if field := dnsmasqBootField(name); field != nil {
+				*field(&boot) = data
+				continue
+			}
+			h.host.Options.Set("option "+name, data)
+		case "dhcp-boot":
+			h.related = append(h.related, i)
+			boot.Filename = fields[1]
+			if len(fields) > 3 {
+				boot.NextServer = fields[3]
+			}
+		}
+	}
+	if boot != (models.HostBoot{}) {
+		h.host.Boot = &boot
+	}
+}
+
+func dnsmasqHostField(name string) func(h *models.Host) *string {
+	for _, o := range dnsmasqHostOptions {
+		if o.name == name {
+			return o.field
+		}
+	}
+	return nil
+}
+
+func dnsmasqBootField(name string) func(b *models.HostBoot) *string {
+	for _, o := range dnsmasqBootOptions {
+		if o.name == name {
+			return o.field
+		}
+	}
+	return nil
+}
+
+func (c *dnsmasqConfig) modelHosts() []models.Host {
+	var hosts []models.Host
+	for _, h := range c.hosts() {
+		hosts = append(hosts, h.host)
+	}
+	return hosts
+}
+
+func (c *dnsmasqConfig) findHost(name string) *dnsmasqHost {
+	for _, h := range c.hosts() {
+		if h.host.Name == name {
+			return &h
+		}
+	}
+	return nil
+}
+
+// dnsmasqHostLines renders a host as its dhcp-host line and tagged option lines
+func dnsmasqHostLines(host models.Host, tag string, extra []string) ([]string, error) {
+	var boot models.HostBoot
+	if host.Boot != nil {
+		boot = *host.Boot
+	}
+	for _, opt := range host.Options {
+		name, ok := strings.CutPrefix(opt.Name, "option ")
+		if !ok || !dnsmasqOptionNameRegex.MatchString(name) {
+			return nil, &FieldError{"options." + opt.Name, `the dnsmasq backend only supports DHCP options, named "option <name>"`}
+		}
+		if strings.ContainsAny(opt.Value, "\r\n#") {
+			return nil, &FieldError{"options." + opt.Name, "value must not contain line breaks or #"}
+		}
+	}
+	for _, value := range []string{boot.Filename, boot.TFTPServerName, boot.BootfileName} {
+		if strings.ContainsAny(value, "\r\n#,") {
+			return nil, &FieldError{"boot", "values must not contain line breaks, # or commas"}
+		}
+	}
+	if boot.NextServer != "" && boot.Filename == "" {
+		return nil, &FieldError{"boot.filename", "required by the dnsmasq backend when next_server is set"}
+	}
+
+	var options []string
+	for _, o := range dnsmasqHostOptions {
+		if value := *o.field(&host); value != "" {
+			options = append(options, o.name, strings.ReplaceAll(value, ", ", ","))
+		}
+	}
+	for _, o := range dnsmasqBootOptions {
+		if value := *o.field(&boot); value != "" {
+			options = append(options, o.name, value)
+		}
+	}
+	for _, opt := range host.Options {
+		options = append(options, strings.TrimPrefix(opt.Name, "option "), opt.Value)
+	}
+	if (len(options) > 0 || boot.Filename != "") && tag == "" {
+		tag = "host-" + host.Name
+	}
+
+	fields := []string{host.HardwareEthernet}
+	if tag != "" {
+		fields = append(fields, "set:"+tag)
+	}
+	fields = append(fields, host.FixedAddress, host.Name)
+	fields = append(fields, extra...)
+	lines := []string{"dhcp-host=" + strings.Join(fields, ",")}
+	for i := 0; i < len(options); i += 2 {
+		lines = append(lines, fmt.Sprintf("dhcp-option=tag:%s,option:%s,%s", tag, options[i], options[i+1]))
+	}
+	if boot.Filename != "" {
+		line := fmt.Sprintf("dhcp-boot=tag:%s,%s", tag, boot.Filename)
+		if boot.NextServer != "" {
+			line += ",," + boot.NextServer
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// replaceHost swaps the lines of old, if any, for lines. New hosts are
+// appended.
+func (c *dnsmasqConfig) replaceHost(old *dnsmasqHost, lines []string) {
+	if old == nil {
+		c.lines = append(c.lines, lines...)
+		return
+	}
+	drop := append([]int{old.line}, old.related...)
+	var out []string
+	for i, line := range c.lines {
+		if i == old.line {
+			out = append(out, lines...)
+		}
+		if !slices.Contains(drop, i) {
+			out = append(out, line)
+		}
+	}
+	c.lines = out
+}
+
+func (dnsmasqBackend) ListHosts(query models.HostQuery) ([]models.Host, int, error) {
+	c, err := loadDnsmasqConfig()
+	if err != nil {
+		return nil, 0, err
+	}
+	return queryHosts(c.modelHosts(), query)
+}
+
+func (dnsmasqBackend) GetHost(name string) (models.Host, error) {
+	c, err := loadDnsmasqConfig()
+	if err != nil {
+		return models.Host{}, err
+	}
+	return findHostIn(c.modelHosts(), name)
+}
+
+func (dnsmasqBackend) AddHost(host models.Host) (added models.Host, err error) {
+	err = editDnsmasqConfig(func(c *dnsmasqConfig) error {
+		if err := checkPortableHost(&host, "dnsmasq"); err != nil {
+			return err
+		}
+		if err := hostConflictIn(c.modelHosts(), host, ""); err != nil {
+			return err
+		}
+		lines, err := dnsmasqHostLines(host, "", nil)
+		if err != nil {
+			return err
+		}
+		c.replaceHost(nil, lines)
+		added, err = findHostIn(c.modelHosts(), host.Name)
+		return err
+	})
+	return added, err
+}
+
+func (dnsmasqBackend) UpdateHost(name string, updates models.HostUpdate, cond Precondition) (before, after models.Host, err error) {
+	err = editDnsmasqConfig(func(c *dnsmasqConfig) error {
+		hosts := c.modelHosts()
+		if err := cond.check(hostETagIn(hosts, name)); err != nil {
+			return err
+		}
+		before, _ = findHostIn(hosts, name)
+		if err := validateHostUpdate(&updates); err != nil {
+			return err
+		}
+		old := c.findHost(name)
+		if old == nil {
+			log.Printf("Host %s not found for update.", name)
+			return fmt.Errorf("host %s %w", name, ErrNotFound)
+		}
+
+		host := old.host
+		mergeHostUpdate(&host, updates)
+		if err := checkPortableHost(&host, "dnsmasq"); err != nil {
+			return err
+		}
+		if err := hostConflictIn(hosts, host, name); err != nil {
+			return err
+		}
+		lines, err := dnsmasqHostLines(host, old.tag, old.extra)
+		if err != nil {
+			return err
+		}
+		c.replaceHost(old, lines)
+		after, err = findHostIn(c.modelHosts(), host.Name)
+		return err
+	})
+	return before, after, err
+}
+
+func (dnsmasqBackend) DeleteHost(name string, cond Precondition) (deleted models.Host, err error) {
+	err = editDnsmasqConfig(func(c *dnsmasqConfig) error {
+		hosts := c.modelHosts()
+		if err := cond.check(hostETagIn(hosts, name)); err != nil {
+			return err
+		}
+		deleted, _ = findHostIn(hosts, name)
+		old := c.findHost(name)
+		if old == nil {
+			log.Printf("Host %s not found for deletion.", name)
+			return errUnchanged // idempotent delete
+		}
+		c.replaceHost(old, nil)
+		return nil
+	})
+	return deleted, err
+}
+
+// interfaces returns the interface= lines in the form served by GET
+// /interfaces. dnsmasq serves DHCPv4 and DHCPv6 on the same interfaces,
+// which are reported as v4.
+func (c *dnsmasqConfig) interfaces() map[string]string {
+	var names []string
+	for _, line := range c.lines {
+		if key, value := directive(line); key == "interface" {
+			for _, name := range splitFields(value) {
+				if name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return map[string]string{"v4": strings.Join(names, " "), "v6": ""}
+}
+
+func (dnsmasqBackend) GetInterfaces() (map[string]string, error) {
+	c, err := loadDnsmasqConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.interfaces(), nil
+}
+
+// editDnsmasqInterfaces is editInterfaces for the interface= lines, which
+// are rewritten one per interface where the first one was
+func editDnsmasqInterfaces(cond Precondition, edit func(interfaces map[string]string) error) (bool, error) {
+	changed := true
+	err := editDnsmasqConfig(func(c *dnsmasqConfig) error {
+		interfaces := c.interfaces()
+		if err := cond.check(ETag(interfaces)); err != nil {
+			return err
+		}
+		if err := edit(interfaces); err != nil {
+			changed = !errors.Is(err, errUnchanged)
+			return err
+		}
+		if interfaces["v6"] != "" {
+			return &FieldError{"type", "dnsmasq serves DHCPv6 on its v4 interfaces, add the interface as v4"}
+		}
+
+		var ifaceLines []string
+		for _, name := range strings.Fields(interfaces["v4"]) {
+			if strings.ContainsAny(name, ",#") {
+				return &FieldError{"interface", fmt.Sprintf("invalid interface name %q", name)}
+			}
+			ifaceLines = append(ifaceLines, "interface="+name)
+		}
+		var out []string
+		placed := false
+		for _, line := range c.lines {
+			if key, _ := directive(line); key == "interface" {
+				if !placed {
+					out = append(out, ifaceLines...)
+					placed = true
+				}
+				continue
+			}
+			out = append(out, line)
+		}
+		if !placed {
+			out = append(out, ifaceLines...)
+		}
+		c.lines = out
+		return nil
+	})
+	return changed && err == nil, err
+}
+
+func (dnsmasqBackend) AddInterface(ifaceType, ifaceName string, cond Precondition) (bool, error) {
+	return editDnsmasqInterfaces(cond, func(interfaces map[string]string) error {
+		return addInterface(interfaces, ifaceType, ifaceName)
+	})
+}
+
+func (dnsmasqBackend) DeleteInterface(ifaceType, ifaceName string, cond Precondition) (bool, error) {
+	return editDnsmasqInterfaces(cond, func(interfaces map[string]string) error {
+		return deleteInterface(interfaces, ifaceType, ifaceName)
+	})
+}
+
+func (dnsmasqBackend) ListLeases(filter models.LeaseFilter) ([]models.Lease, error) {
+	leases, err := readDnsmasqLeases()
+	if err != nil {
+		return nil, err
+	}
+	return filterLeases(leases, filter)
+}
+
+// readDnsmasqLeases parses dnsmasq.leases, which holds one line per
+// current lease: `<expiry> <mac> <ip> <hostname> <client-id>`, with * for
+// unknown values and an expiry of 0 for infinite leases
+func readDnsmasqLeases() ([]models.Lease, error) {
+	path := config.AppConfig.LeasesPath
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Leases file not found at %s, returning no leases", path)
+			return nil, nil
+		}
+		log.Printf("Error reading leases file: %v", err)
+		return nil, fmt.Errorf("failed to read leases file: %w", err)
+	}
+
+	now := time.Now()
+	latest := make(map[string]models.Lease)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !isIPv4(fields[2]) {
+			continue // DHCPv6 leases and the duid line
+		}
+		known := func(s string) string {
+			if s == "*" {
+				return ""
+			}
+			return s
+		}
+		lease := models.Lease{
+			IP:               fields[2],
+			BindingState:     "active",
+			HardwareEthernet: fields[1],
+			ClientHostname:   known(fields[3]),
+		}
+		if len(fields) > 4 {
+			lease.UID = known(fields[4])
+		}
+		if expiry, err := strconv.ParseInt(fields[0], 10, 64); err == nil && expiry > 0 {
+			ends := time.Unix(expiry, 0).UTC()
+			lease.Ends = &ends
+			if ends.Before(now) {
+				lease.BindingState = "expired"
+			}
+		}
+		latest[lease.IP] = lease
+	}
+	return leasesByAddress(latest), nil
+}
+
+func (b dnsmasqBackend) ReserveLease(ip string, req models.LeaseReservation) (models.Host, error) {
+	leases, err := readDnsmasqLeases()
+	if err != nil {
+		return models.Host{}, err
+	}
+	host, err := leaseHost(leases, ip, req)
+	if err != nil {
+		return models.Host{}, err
+	}
+	return b.AddHost(host)
+}
+
+func (dnsmasqBackend) ApplyChanges() models.ApplyResult { return ApplyChanges() }
+
+func (dnsmasqBackend) NotifyChanged() models.ApplyResult { return NotifyChanged() }
+
+func (dnsmasqBackend) HostsChanged() models.ApplyResult { return NotifyChanged() }
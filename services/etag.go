@@ -0,0 +1,54 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrPreconditionFailed is returned when an If-Match or If-None-Match
+// condition does not hold for the current state of a resource
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ETag returns a strong entity tag for the JSON representation of v
+func ETag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// Precondition holds the If-Match and If-None-Match headers of a write.
+// The zero value always holds.
+type Precondition struct {
+	IfMatch     string
+	IfNoneMatch string
+}
+
+// check tests the precondition against the current entity tag of a
+// resource; current is "" when the resource does not exist
+func (p Precondition) check(current string) error {
+	if p.IfMatch != "" {
+		if current == "" || (strings.TrimSpace(p.IfMatch) != "*" && !etagListContains(p.IfMatch, current)) {
+			return ErrPreconditionFailed
+		}
+	}
+	if p.IfNoneMatch != "" && current != "" {
+		if strings.TrimSpace(p.IfNoneMatch) == "*" || etagListContains(p.IfNoneMatch, current) {
+			return ErrPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// etagListContains reports whether a comma-separated header value lists
+// etag. Weak tags compare by their opaque part.
+func etagListContains(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
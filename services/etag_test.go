@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+func TestPreconditionCheck(t *testing.T) {
+	const current = `"abc"`
+	tests := []struct {
+		name    string
+		cond    Precondition
+		current string
+		ok      bool
+	}{
+		{"none", Precondition{}, current, true},
+		{"if-match current", Precondition{IfMatch: current}, current, true},
+		{"if-match in list", Precondition{IfMatch: `"old", "abc"`}, current, true},
+		{"if-match weak", Precondition{IfMatch: `W/"abc"`}, current, true},
+		{"if-match stale", Precondition{IfMatch: `"old"`}, current, false},
+		{"if-match any", Precondition{IfMatch: "*"}, current, true},
+		{"if-match missing", Precondition{IfMatch: "*"}, "", false},
+		{"if-none-match any", Precondition{IfNoneMatch: "*"}, current, false},
+		{"if-none-match any missing", Precondition{IfNoneMatch: "*"}, "", true},
+		{"if-none-match other", Precondition{IfNoneMatch: `"old"`}, current, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cond.check(tt.current)
+			if tt.ok != (err == nil) || (err != nil && !errors.Is(err, ErrPreconditionFailed)) {
+				t.Errorf("check = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestUpdateHostStaleIfMatch(t *testing.T) {
+	path := useDhcpConf(t, "host web {\n    hardware ethernet 00:11:22:33:44:55;\n    fixed-address 10.0.0.5;\n}\n")
+
+	host, err := GetHost("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := ETag(host)
+	address := "10.0.0.6"
+	_, after, err := UpdateHost("web", models.HostUpdate{FixedAddress: &address}, Precondition{IfMatch: stale})
+	if err != nil {
+		t.Fatalf("UpdateHost with the current ETag: %v", err)
+	}
+
+	// The first update changed the host, so its old ETag no longer matches
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address = "10.0.0.7"
+	if _, _, err := UpdateHost("web", models.HostUpdate{FixedAddress: &address}, Precondition{IfMatch: stale}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("UpdateHost with a stale ETag = %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := DeleteHost("web", Precondition{IfMatch: stale}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("DeleteHost with a stale ETag = %v, want ErrPreconditionFailed", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != string(before) {
+		t.Errorf("a refused write changed the file:\n%s", content)
+	}
+
+	// The ETag returned with the update is the current one
+	if _, err := DeleteHost("web", Precondition{IfMatch: ETag(after)}); err != nil {
+		t.Errorf("DeleteHost with the new ETag: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	fileMutexesMu sync.Mutex
+	fileMutexes   = map[string]*sync.Mutex{}
+)
+
+// renameFile is os.Rename, replaceable by tests
+var renameFile = os.Rename
+
+// fileMutex returns the in-process mutex guarding path
+func fileMutex(path string) *sync.Mutex {
+	fileMutexesMu.Lock()
+	defer fileMutexesMu.Unlock()
+	mu, ok := fileMutexes[path]
+	if !ok {
+		mu = &sync.Mutex{}
+		fileMutexes[path] = mu
+	}
+	return mu
+}
+
+// withFileLock runs fn while holding both the in-process mutex for path and
+// an advisory lock on `<path>.lock`. The lock lives in a sidecar file
+// because the config itself is replaced by rename on every write; other
+// tools editing the file can take the same flock to stay out of our way.
+func withFileLock(path string, fn func() error) error {
+	mu := fileMutex(path)
+	mu.Lock()
+	defer mu.Unlock()
+
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		log.Printf("Error opening lock file for %s: %v", path, err)
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	defer lock.Close()
+
+	if err := flock(lock); err != nil {
+		log.Printf("Error locking %s: %v", path, err)
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	defer funlock(lock)
+
+	return fn()
+}
+
+// stageFile writes data to a temp file in the same directory as path and
+// syncs it, ready for commitFile to rename over path. The original mode and
+// owner are kept. The caller removes the temp file if it is not committed.
+func stageFile(path string, data []byte) (string, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return tmpPath, err
+	}
+
+	// CreateTemp uses 0600 and the process owner; keep those of the file
+	// being replaced
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		if err := preserveOwner(tmp, info); err != nil {
+			log.Printf("Warning: could not keep owner of %s: %v", path, err)
+		}
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return tmpPath, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return tmpPath, err
+	}
+	return tmpPath, tmp.Close()
+}
+
+// commitFile atomically replaces path with a file staged by stageFile
+func commitFile(tmpPath, path string) error {
+	if err := renameFile(tmpPath, path); err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the directory entry after a rename. Failures are only
+// logged; the data itself is already on disk.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		log.Printf("Warning: could not sync directory %s: %v", dir, err)
+	}
+}
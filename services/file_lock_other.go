@@ -0,0 +1,14 @@
+//go:build !unix
+
+package services
+
+import "os"
+
+// Advisory locks and ownership are only supported on unix; elsewhere the
+// in-process mutex is all the protection there is.
+
+func flock(f *os.File) error { return nil }
+
+func funlock(f *os.File) {}
+
+func preserveOwner(f *os.File, info os.FileInfo) error { return nil }
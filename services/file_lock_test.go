@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// useDhcpConf points DHCP_CONF_PATH at a temp file holding content, with
+// the syntax check, history and allocation exclusions disabled, and
+// returns its path
+func useDhcpConf(t *testing.T, content string) string {
+	t.Helper()
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+
+	path := filepath.Join(t.TempDir(), "dhcpd.conf")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config.AppConfig.DhcpConfPath = path
+	config.AppConfig.CheckCommand = ""
+	config.AppConfig.HistoryDir = ""
+	config.AppConfig.AllocationExclude = ""
+	return path
+}
+
+func TestConcurrentAddHost(t *testing.T) {
+	useDhcpConf(t, "subnet 10.0.0.0 netmask 255.255.255.0 {\n}\n")
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := AddHost(models.Host{
+				Name:             fmt.Sprintf("host%02d", i),
+				HardwareEthernet: fmt.Sprintf("00:11:22:33:44:%02x", i),
+				FixedAddress:     fmt.Sprintf("10.0.0.%d", i+10),
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("AddHost: %v", err)
+		}
+	}
+
+	hosts, total, err := ListHosts(models.HostQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != n {
+		t.Errorf("%d hosts survived, want %d: %v", total, n, hosts)
+	}
+}
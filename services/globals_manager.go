@@ -0,0 +1,65 @@
+package services
+
+import (
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+func GetGlobals() (models.Globals, error) {
+	f, err := loadDhcpConf()
+	if err != nil {
+		return models.Globals{}, err
+	}
+
+	g := globalScope{f}
+	return models.Globals{
+		OptionDomainName:        dhcpconf.Unquote(getParam(g, "option", "domain-name")),
+		OptionDomainNameServers: getParam(g, "option", "domain-name-servers"),
+		DefaultLeaseTime:        getIntParam(g, "default-lease-time"),
+		MaxLeaseTime:            getIntParam(g, "max-lease-time"),
+		Authoritative:           f.Find("authoritative") != nil,
+		DdnsUpdateStyle:         getParam(g, "ddns-update-style"),
+		LogFacility:             getParam(g, "log-facility"),
+	}, nil
+}
+
+func UpdateGlobals(updates models.GlobalsUpdate) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		g := globalScope{f}
+
+		if updates.OptionDomainName != nil {
+			if err := setStringParam(g, []string{"option", "domain-name"}, *updates.OptionDomainName); err != nil {
+				return err
+			}
+		}
+		if updates.OptionDomainNameServers != nil {
+			if err := setParam(g, []string{"option", "domain-name-servers"}, *updates.OptionDomainNameServers); err != nil {
+				return err
+			}
+		}
+		if updates.DefaultLeaseTime != nil {
+			if err := setIntParam(g, []string{"default-lease-time"}, *updates.DefaultLeaseTime); err != nil {
+				return err
+			}
+		}
+		if updates.MaxLeaseTime != nil {
+			if err := setIntParam(g, []string{"max-lease-time"}, *updates.MaxLeaseTime); err != nil {
+				return err
+			}
+		}
+		if updates.Authoritative != nil {
+			setFlag(g, *updates.Authoritative, "authoritative")
+		}
+		if updates.DdnsUpdateStyle != nil {
+			if err := setParam(g, []string{"ddns-update-style"}, *updates.DdnsUpdateStyle); err != nil {
+				return err
+			}
+		}
+		if updates.LogFacility != nil {
+			if err := setParam(g, []string{"log-facility"}, *updates.LogFacility); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
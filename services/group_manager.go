@@ -0,0 +1,336 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+func ListGroups() ([]models.Group, error) {
+	f, err := loadDhcpConf()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []models.Group
+	dhcpconf.Walk(f.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+		b, ok := n.(*dhcpconf.Block)
+		if !ok || b.Keyword() == "host" {
+			return false
+		}
+		if b.Keyword() == "group" {
+			group := groupFromBlock(b)
+			for _, p := range parents {
+				if p.Keyword() == "subnet" && len(p.Args) == 4 {
+					group.Subnet = p.Args[1]
+				}
+			}
+			groups = append(groups, group)
+		}
+		return true
+	})
+	return groups, nil
+}
+
+// findGroup returns the named group declaration, wherever it is nested
+func findGroup(f *dhcpconf.File, name string) *dhcpconf.Block {
+	var found *dhcpconf.Block
+	dhcpconf.Walk(f.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+		b, ok := n.(*dhcpconf.Block)
+		if !ok || found != nil || b.Keyword() == "host" {
+			return false
+		}
+		if b.Keyword() == "group" && name != "" && b.Name() == name {
+			found = b
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func groupFromBlock(b *dhcpconf.Block) models.Group {
+	group := models.Group{
+		Name:                    b.Name(),
+		OptionRouters:           getParam(b, "option", "routers"),
+		OptionSubnetMask:        getParam(b, "option", "subnet-mask"),
+		OptionDomainNameServers: getParam(b, "option", "domain-name-servers"),
+		OptionDomainName:        dhcpconf.Unquote(getParam(b, "option", "domain-name")),
+		DefaultLeaseTime:        getIntParam(b, "default-lease-time"),
+		MaxLeaseTime:            getIntParam(b, "max-lease-time"),
+	}
+	for _, host := range b.Blocks("host") {
+		group.Hosts = append(group.Hosts, host.Name())
+	}
+	return group
+}
+
+func AddGroup(group models.Group) error {
+	if err := validateIdentifier("name", group.Name); err != nil {
+		return err
+	}
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		if findGroup(f, group.Name) != nil {
+			return fmt.Errorf("group %s already exists", group.Name)
+		}
+
+		b := dhcpconf.NewBlock("group", group.Name)
+		if err := applyGroupUpdate(b, models.GroupUpdate{
+			OptionRouters:           &group.OptionRouters,
+			OptionSubnetMask:        &group.OptionSubnetMask,
+			OptionDomainNameServers: &group.OptionDomainNameServers,
+			OptionDomainName:        &group.OptionDomainName,
+			DefaultLeaseTime:        &group.DefaultLeaseTime,
+			MaxLeaseTime:            &group.MaxLeaseTime,
+		}); err != nil {
+			return err
+		}
+
+		if group.Subnet != "" {
+			subnet := findSubnet(f, group.Subnet)
+			if subnet == nil {
+				return &FieldError{"subnet", fmt.Sprintf("subnet %s is not declared", group.Subnet)}
+			}
+			subnet.Append(b)
+			return nil
+		}
+		insertDeclaration(f, b)
+		return nil
+	})
+}
+
+func UpdateGroup(name string, updates models.GroupUpdate) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		b := findGroup(f, name)
+		if b == nil {
+			log.Printf("Group %s not found for update.", name)
+			return fmt.Errorf("group %s %w", name, ErrNotFound)
+		}
+
+		if updates.Name != nil && *updates.Name != "" && *updates.Name != name {
+			if err := validateIdentifier("name", *updates.Name); err != nil {
+				return err
+			}
+			if findGroup(f, *updates.Name) != nil {
+				return fmt.Errorf("group %s already exists", *updates.Name)
+			}
+			b.Args[1] = *updates.Name
+		}
+		return applyGroupUpdate(b, updates)
+	})
+}
+
+func applyGroupUpdate(b *dhcpconf.Block, updates models.GroupUpdate) error {
+	if updates.OptionRouters != nil {
+		if err := setParam(b, []string{"option", "routers"}, *updates.OptionRouters); err != nil {
+			return err
+		}
+	}
+	if updates.OptionSubnetMask != nil {
+		if err := setParam(b, []string{"option", "subnet-mask"}, *updates.OptionSubnetMask); err != nil {
+			return err
+		}
+	}
+	if updates.OptionDomainNameServers != nil {
+		if err := setParam(b, []string{"option", "domain-name-servers"}, *updates.OptionDomainNameServers); err != nil {
+			return err
+		}
+	}
+	if updates.OptionDomainName != nil {
+		if err := setStringParam(b, []string{"option", "domain-name"}, *updates.OptionDomainName); err != nil {
+			return err
+		}
+	}
+	if updates.DefaultLeaseTime != nil {
+		if err := setIntParam(b, []string{"default-lease-time"}, *updates.DefaultLeaseTime); err != nil {
+			return err
+		}
+	}
+	if updates.MaxLeaseTime != nil {
+		if err := setIntParam(b, []string{"max-lease-time"}, *updates.MaxLeaseTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteGroup removes an empty group; groups that still hold hosts, in
+// them or in nested groups, must be emptied first so reservations are
+// never dropped by accident. It reports whether the group was there.
+func DeleteGroup(name string) (bool, error) {
+	deleted := false
+	err := editDhcpConf(func(f *dhcpconf.File) error {
+		b := findGroup(f, name)
+		if b == nil {
+			log.Printf("Group %s not found for deletion.", name)
+			return errUnchanged // idempotent delete
+		}
+		var conflict *ConflictError
+		dhcpconf.Walk(f.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+			host, ok := n.(*dhcpconf.Block)
+			if conflict != nil || !ok {
+				return false
+			}
+			if host.Keyword() == "host" && slices.Contains(parents, b) {
+				inUse := hostFromBlock(host)
+				inUse.Group, inUse.SharedNetwork = hostLocation(parents)
+				conflict = &ConflictError{"group", name, inUse}
+			}
+			return true
+		})
+		if conflict != nil {
+			return conflict
+		}
+		f.Remove(b)
+		deleted = true
+		return nil
+	})
+	return deleted && err == nil, err
+}
+
+func ListSharedNetworks() ([]models.SharedNetwork, error) {
+	f, err := loadDhcpConf()
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []models.SharedNetwork
+	for _, b := range f.Blocks("shared-network") {
+		networks = append(networks, sharedNetworkFromBlock(b))
+	}
+	return networks, nil
+}
+
+func findSharedNetwork(f *dhcpconf.File, name string) *dhcpconf.Block {
+	for _, b := range f.Blocks("shared-network") {
+		if b.Name() == name {
+			return b
+		}
+	}
+	return nil
+}
+
+func sharedNetworkFromBlock(b *dhcpconf.Block) models.SharedNetwork {
+	shared := models.SharedNetwork{Name: b.Name(), Subnets: []string{}}
+	for _, subnet := range b.Blocks("subnet") {
+		if len(subnet.Args) == 4 {
+			shared.Subnets = append(shared.Subnets, subnet.Args[1])
+		}
+	}
+	return shared
+}
+
+// AddSharedNetwork declares a shared network and moves the listed top-level
+// subnets into it
+func AddSharedNetwork(shared models.SharedNetwork) error {
+	if err := validateIdentifier("name", shared.Name); err != nil {
+		return err
+	}
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		if findSharedNetwork(f, shared.Name) != nil {
+			return fmt.Errorf("shared-network %s already exists", shared.Name)
+		}
+
+		b := dhcpconf.NewBlock("shared-network", shared.Name)
+		insertDeclaration(f, b)
+		return setSharedNetworkSubnets(f, b, shared.Subnets)
+	})
+}
+
+func UpdateSharedNetwork(name string, updates models.SharedNetworkUpdate) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		b := findSharedNetwork(f, name)
+		if b == nil {
+			log.Printf("Shared-network %s not found for update.", name)
+			return fmt.Errorf("shared-network %s %w", name, ErrNotFound)
+		}
+
+		if updates.Name != nil && *updates.Name != "" && *updates.Name != name {
+			if err := validateIdentifier("name", *updates.Name); err != nil {
+				return err
+			}
+			if findSharedNetwork(f, *updates.Name) != nil {
+				return fmt.Errorf("shared-network %s already exists", *updates.Name)
+			}
+			b.Args[1] = *updates.Name
+		}
+		if updates.Subnets != nil {
+			return setSharedNetworkSubnets(f, b, *updates.Subnets)
+		}
+		return nil
+	})
+}
+
+// setSharedNetworkSubnets makes networks the exact subnet membership of a
+// shared network. Listed subnets must currently be top-level or already
+// members; members that are not listed are moved back to the top level.
+func setSharedNetworkSubnets(f *dhcpconf.File, shared *dhcpconf.Block, networks []string) error {
+	for _, network := range networks {
+		subnet := findSubnet(f, network)
+		if subnet == nil {
+			return &FieldError{"subnets", fmt.Sprintf("subnet %s is not declared", network)}
+		}
+		if slices.Contains(shared.Body, dhcpconf.Node(subnet)) {
+			continue
+		}
+		if !slices.Contains(f.Body, dhcpconf.Node(subnet)) {
+			return fmt.Errorf("subnet %s already belongs to another shared-network", network)
+		}
+		f.Remove(subnet)
+		dhcpconf.Reset(subnet)
+		shared.Append(subnet)
+	}
+
+	// Walk backwards so moved subnets keep their relative order
+	members := shared.Blocks("subnet")
+	for i := len(members) - 1; i >= 0; i-- {
+		subnet := members[i]
+		if len(subnet.Args) == 4 && !slices.Contains(networks, subnet.Args[1]) {
+			shared.Remove(subnet)
+			dhcpconf.Reset(subnet)
+			f.InsertAfter(shared, subnet)
+		}
+	}
+	return nil
+}
+
+// DeleteSharedNetwork removes a shared-network declaration. Its subnets and
+// comments are kept and take its place as top-level declarations; a
+// shared-network holding anything else, such as options or pools, is
+// refused, since moving those to the top level would change what they
+// apply to. It reports whether the shared-network was there.
+func DeleteSharedNetwork(name string) (bool, error) {
+	deleted := false
+	err := editDhcpConf(func(f *dhcpconf.File) error {
+		b := findSharedNetwork(f, name)
+		if b == nil {
+			log.Printf("Shared-network %s not found for deletion.", name)
+			return errUnchanged // idempotent delete
+		}
+		for _, n := range b.Body {
+			switch n := n.(type) {
+			case *dhcpconf.Statement:
+				return fmt.Errorf("shared-network %s still contains %s; remove everything but its subnets first", name, dhcpconf.Join(n.Args)+";")
+			case *dhcpconf.Block:
+				if n.Keyword() != "subnet" {
+					return fmt.Errorf("shared-network %s still contains %s; remove everything but its subnets first", name, dhcpconf.Join(n.Args)+" { ... }")
+				}
+			}
+		}
+
+		prev := dhcpconf.Node(b)
+		for _, n := range slices.Clone(b.Body) {
+			b.Remove(n)
+			dhcpconf.Reset(n)
+			f.InsertAfter(prev, n)
+			prev = n
+		}
+		f.Remove(b)
+		deleted = true
+		return nil
+	})
+	return deleted && err == nil, err
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// Snapshot IDs are `<UTC timestamp>-<file name>`, which is also the name of
+// the snapshot in HISTORY_DIR. The fixed-width timestamp keeps IDs sortable.
+const snapshotTimeFormat = "20060102T150405.000000000Z"
+
+func parseSnapshotID(id string) (models.HistoryEntry, bool) {
+	stamp, name, ok := strings.Cut(id, "-")
+	if !ok {
+		return models.HistoryEntry{}, false
+	}
+	t, err := time.Parse(snapshotTimeFormat, stamp)
+	if err != nil {
+		return models.HistoryEntry{}, false
+	}
+	if _, ok := managedFile(name); !ok {
+		return models.HistoryEntry{}, false
+	}
+	return models.HistoryEntry{ID: id, File: name, Time: t}, true
+}
+
+// ListHistory returns stored snapshots, newest first, optionally limited
+// to one file
+func ListHistory(file string) ([]models.HistoryEntry, error) {
+	entries, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+	result := []models.HistoryEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if file == "" || entries[i].File == file {
+			result = append(result, entries[i])
+		}
+	}
+	return result, nil
+}
+
+// readHistory lists the history directory, oldest first
+func readHistory() ([]models.HistoryEntry, error) {
+	dir := config.AppConfig.HistoryDir
+	if dir == "" {
+		return nil, nil
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		log.Printf("Error reading history directory %s: %v", dir, err)
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var entries []models.HistoryEntry
+	for _, f := range files {
+		entry, ok := parseSnapshotID(f.Name())
+		if !ok || !f.Type().IsRegular() {
+			continue
+		}
+		if info, err := f.Info(); err == nil {
+			entry.Size = info.Size()
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// GetHistory returns one snapshot together with its diff against the
+// previous snapshot of the same file
+func GetHistory(id string) (models.HistoryDetail, error) {
+	entries, err := readHistory()
+	if err != nil {
+		return models.HistoryDetail{}, err
+	}
+
+	var previous *models.HistoryEntry
+	for i, entry := range entries {
+		if entry.ID != id {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if entries[j].File == entry.File {
+				previous = &entries[j]
+				break
+			}
+		}
+
+		content, err := readSnapshot(entry.ID)
+		if err != nil {
+			return models.HistoryDetail{}, err
+		}
+		detail := models.HistoryDetail{HistoryEntry: entry, Content: string(content)}
+		var old []byte
+		if previous != nil {
+			detail.Previous = previous.ID
+			if old, err = readSnapshot(previous.ID); err != nil {
+				return models.HistoryDetail{}, err
+			}
+		}
+		detail.Diff = unifiedDiff(detail.Previous, entry.ID, old, content)
+		return detail, nil
+	}
+	return models.HistoryDetail{}, fmt.Errorf("snapshot %s %w", id, ErrNotFound)
+}
+
+func readSnapshot(id string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(config.AppConfig.HistoryDir, id))
+	if err != nil {
+		log.Printf("Error reading snapshot %s: %v", id, err)
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return content, nil
+}
+
+// RestoreHistory writes a snapshot back over its file. The restore goes
+// through the usual syntax check and is itself snapshotted, so it can be
+// undone the same way.
+func RestoreHistory(id string) error {
+	entry, ok := parseSnapshotID(id)
+	if !ok {
+		return fmt.Errorf("snapshot %s %w", id, ErrNotFound)
+	}
+	content, err := os.ReadFile(filepath.Join(config.AppConfig.HistoryDir, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("snapshot %s %w", id, ErrNotFound)
+		}
+		log.Printf("Error reading snapshot %s: %v", id, err)
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	conf, _ := managedFile(entry.File)
+	return withFileLock(conf.path, func() error {
+		return replaceConfFile(conf, content)
+	})
+}
+
+// snapshotFile stores the current content of conf before it is replaced
+// and prunes old snapshots beyond HISTORY_RETENTION. The caller must hold
+// the file's lock. Nothing is stored if the content matches the latest
+// snapshot or the file does not exist yet.
+func snapshotFile(conf confFile) error {
+	dir := config.AppConfig.HistoryDir
+	if dir == "" {
+		return nil
+	}
+	content, err := os.ReadFile(conf.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to snapshot %s: %w", conf.name, err)
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		return err
+	}
+	var own []models.HistoryEntry
+	for _, entry := range entries {
+		if entry.File == conf.name {
+			own = append(own, entry)
+		}
+	}
+	if len(own) > 0 {
+		latest, err := os.ReadFile(filepath.Join(dir, own[len(own)-1].ID))
+		if err == nil && bytes.Equal(latest, content) {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		log.Printf("Error creating history directory %s: %v", dir, err)
+		return fmt.Errorf("failed to snapshot %s: %w", conf.name, err)
+	}
+	id := time.Now().UTC().Format(snapshotTimeFormat) + "-" + conf.name
+	// Snapshots may hold DDNS keys and the like, keep them private
+	if err := os.WriteFile(filepath.Join(dir, id), content, 0600); err != nil {
+		log.Printf("Error writing snapshot %s: %v", id, err)
+		return fmt.Errorf("failed to snapshot %s: %w", conf.name, err)
+	}
+	own = append(own, models.HistoryEntry{ID: id})
+
+	if retention := config.AppConfig.HistoryRetention; retention > 0 && len(own) > retention {
+		for _, entry := range own[:len(own)-retention] {
+			if err := os.Remove(filepath.Join(dir, entry.ID)); err != nil {
+				log.Printf("Warning: could not prune snapshot %s: %v", entry.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"slices"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// ipxeCondition is the test dhcpd uses to tell iPXE apart from the PXE ROM
+var ipxeCondition = []string{"if", "exists", "user-class", "and", "option", "user-class", "=", `"iPXE"`}
+
+// bootParams are the host statements represented by models.HostBoot
+var bootParams = [][]string{
+	{"next-server"},
+	{"filename"},
+	{"option", "tftp-server-name"},
+	{"option", "bootfile-name"},
+}
+
+func isBootParam(s *dhcpconf.Statement) bool {
+	for _, keyword := range bootParams {
+		if _, ok := s.Match(keyword...); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isIPXEBlock reports whether b is an `if` testing for the iPXE user class
+func isIPXEBlock(b *dhcpconf.Block) bool {
+	return b.Keyword() == "if" && slices.Contains(b.Args, "user-class") && slices.Contains(b.Args, `"iPXE"`)
+}
+
+// ipxeBlocks returns the iPXE conditional of a host and its else branch
+func ipxeBlocks(host *dhcpconf.Block) (cond, alt *dhcpconf.Block) {
+	for i, n := range host.Body {
+		b, ok := n.(*dhcpconf.Block)
+		if !ok || !isIPXEBlock(b) {
+			continue
+		}
+		for _, next := range host.Body[i+1:] {
+			if _, ok := next.(*dhcpconf.Comment); ok {
+				continue
+			}
+			if e, ok := next.(*dhcpconf.Block); ok && e.Keyword() == "else" {
+				alt = e
+			}
+			break
+		}
+		return b, alt
+	}
+	return nil, nil
+}
+
+func hostBoot(b *dhcpconf.Block) *models.HostBoot {
+	boot := models.HostBoot{
+		NextServer:     getParam(b, "next-server"),
+		Filename:       dhcpconf.Unquote(getParam(b, "filename")),
+		TFTPServerName: dhcpconf.Unquote(getParam(b, "option", "tftp-server-name")),
+		BootfileName:   dhcpconf.Unquote(getParam(b, "option", "bootfile-name")),
+	}
+	if cond, alt := ipxeBlocks(b); cond != nil {
+		boot.IPXEFilename = dhcpconf.Unquote(getParam(cond, "filename"))
+		if alt != nil {
+			boot.Filename = dhcpconf.Unquote(getParam(alt, "filename"))
+		}
+	}
+	if boot == (models.HostBoot{}) {
+		return nil
+	}
+	return &boot
+}
+
+// setHostBoot replaces every boot statement of a host with the given
+// settings
+func setHostBoot(b *dhcpconf.Block, boot models.HostBoot) error {
+	for _, keyword := range bootParams {
+		for s := b.Find(keyword...); s != nil; s = b.Find(keyword...) {
+			b.Remove(s)
+		}
+	}
+	if cond, alt := ipxeBlocks(b); cond != nil {
+		b.Remove(cond)
+		if alt != nil {
+			b.Remove(alt)
+		}
+	}
+
+	if err := setParam(b, []string{"next-server"}, boot.NextServer); err != nil {
+		return err
+	}
+	if err := setStringParam(b, []string{"option", "tftp-server-name"}, boot.TFTPServerName); err != nil {
+		return err
+	}
+	if err := setStringParam(b, []string{"option", "bootfile-name"}, boot.BootfileName); err != nil {
+		return err
+	}
+
+	if boot.IPXEFilename == "" {
+		return setStringParam(b, []string{"filename"}, boot.Filename)
+	}
+
+	cond := dhcpconf.NewBlock(ipxeCondition...)
+	if err := setStringParam(cond, []string{"filename"}, boot.IPXEFilename); err != nil {
+		return err
+	}
+	b.Append(cond)
+	if boot.Filename != "" {
+		alt := dhcpconf.NewBlock("else")
+		if err := setStringParam(alt, []string{"filename"}, boot.Filename); err != nil {
+			return err
+		}
+		b.Append(alt)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// hostColumns are the CSV columns of a host export, in order. CSV carries
+// the address fields and group; options and boot settings need JSON.
+var hostColumns = []struct {
+	name  string
+	field func(h *models.Host) *string
+}{
+	{"name", func(h *models.Host) *string { return &h.Name }},
+	{"hardware_ethernet", func(h *models.Host) *string { return &h.HardwareEthernet }},
+	{"fixed_address", func(h *models.Host) *string { return &h.FixedAddress }},
+	{"option_routers", func(h *models.Host) *string { return &h.OptionRouters }},
+	{"option_subnet_mask", func(h *models.Host) *string { return &h.OptionSubnetMask }},
+	{"option_domain_name_servers", func(h *models.Host) *string { return &h.OptionDomainNameServers }},
+	{"group", func(h *models.Host) *string { return &h.Group }},
+}
+
+// subnetColumn is accepted on import to allocate addresses like POST /hosts
+const subnetColumn = "subnet"
+
+// ExportHosts renders every host reservation of the current backend as
+// CSV or JSON
+func ExportHosts(format string) ([]byte, error) {
+	hosts, _, err := CurrentBackend().ListHosts(models.HostQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "json":
+		return json.MarshalIndent(hosts, "", "  ")
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		header := make([]string, len(hostColumns))
+		for i, col := range hostColumns {
+			header[i] = col.name
+		}
+		w.Write(header)
+		for _, host := range hosts {
+			record := make([]string, len(hostColumns))
+			for i, col := range hostColumns {
+				record[i] = *col.field(&host)
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+	return nil, &FieldError{"format", fmt.Sprintf("unsupported format %q, use csv or json", format)}
+}
+
+// ImportError is returned when rows of an import failed; nothing has been
+// written and Report lists the failures
+type ImportError struct {
+	Report models.ImportReport
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%d of %d rows failed to import", e.Report.Failed, len(e.Report.Rows))
+}
+
+// importRecord is a parsed import row, or the reason it could not be parsed
+type importRecord struct {
+	host models.Host
+	err  error
+}
+
+// ImportHosts adds the hosts in data (CSV with a header row, or a JSON
+// array of hosts) in one rewrite of dhcpd.conf. onConflict decides what
+// happens to rows that clash with an existing host: skip them, overwrite
+// the existing host, or fail. The whole import is rejected if any row
+// fails.
+func ImportHosts(format string, data []byte, onConflict string, dryRun bool) (models.ImportReport, error) {
+	report := models.ImportReport{DryRun: dryRun, Rows: []models.ImportRow{}}
+	switch onConflict {
+	case "skip", "overwrite", "fail":
+	default:
+		return report, &FieldError{"on_conflict", fmt.Sprintf("unsupported policy %q, use skip, overwrite or fail", onConflict)}
+	}
+
+	records, err := parseImport(format, data)
+	if err != nil {
+		return report, err
+	}
+
+	err = editDhcpConf(func(f *dhcpconf.File) error {
+		var imported []importedHost
+		for i, rec := range records {
+			row := importHost(f, rec, onConflict, imported)
+			row.Row = i + 1
+			switch row.Status {
+			case "added":
+				report.Added++
+				imported = append(imported, importedHost{row.Row, row.Name})
+			case "overwritten":
+				report.Overwritten++
+				imported = append(imported, importedHost{row.Row, row.Name})
+			case "skipped":
+				report.Skipped++
+			default:
+				report.Failed++
+			}
+			report.Rows = append(report.Rows, row)
+		}
+		if report.Failed > 0 {
+			return &ImportError{report}
+		}
+		if dryRun || report.Added+report.Overwritten == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	return report, err
+}
+
+// importedHost is a host written by an earlier row of the same import
+type importedHost struct {
+	row  int
+	name string
+}
+
+// importHost adds one record to f according to the conflict policy. A row
+// that clashes with a host written by an earlier row of the same import
+// fails whatever the policy, so one upload cannot overwrite itself.
+func importHost(f *dhcpconf.File, rec importRecord, onConflict string, imported []importedHost) models.ImportRow {
+	host := rec.host
+	row := models.ImportRow{Name: host.Name}
+	fail := func(err error) models.ImportRow {
+		row.Status, row.Error = "error", err.Error()
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			row.Field = fieldErr.Field
+		}
+		var conflictErr *ConflictError
+		if errors.As(err, &conflictErr) {
+			row.Field = conflictErr.Field
+		}
+		return row
+	}
+
+	if rec.err != nil {
+		return fail(rec.err)
+	}
+	if host.Name == "" {
+		return fail(&FieldError{"name", "required"})
+	}
+	if host.HardwareEthernet == "" {
+		return fail(&FieldError{"hardware_ethernet", "required"})
+	}
+
+	candidate := host
+	err := addHost(f, &candidate)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) {
+		if err != nil {
+			return fail(err)
+		}
+		row.Status = "added"
+		return row
+	}
+	for _, earlier := range imported {
+		if conflictErr.Host.Name == earlier.name {
+			return fail(&FieldError{conflictErr.Field, fmt.Sprintf("%s is already used by row %d of this import", conflictErr.Value, earlier.row)})
+		}
+	}
+
+	switch onConflict {
+	case "skip":
+		row.Status, row.Error, row.Field = "skipped", conflictErr.Error(), conflictErr.Field
+		return row
+	case "overwrite":
+		if err := overwriteHost(f, host, conflictErr.Host.Name); err != nil {
+			return fail(err)
+		}
+		row.Status = "overwritten"
+		return row
+	}
+	return fail(err)
+}
+
+// overwriteHost applies an imported row as an update to the existing host
+// named target. The row's non-empty fields replace those of the host;
+// its group, options and boot settings are kept unless the row sets them.
+// A row asking for an allocated address keeps the host's current one.
+func overwriteHost(f *dhcpconf.File, host models.Host, target string) error {
+	optional := func(value string) *string {
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+	updates := models.HostUpdate{
+		Name:                    &host.Name,
+		HardwareEthernet:        &host.HardwareEthernet,
+		OptionRouters:           optional(host.OptionRouters),
+		OptionSubnetMask:        optional(host.OptionSubnetMask),
+		OptionDomainNameServers: optional(host.OptionDomainNameServers),
+		Options:                 host.Options,
+		Boot:                    host.Boot,
+		Group:                   optional(host.Group),
+	}
+	if host.FixedAddress != autoAddress && host.Subnet == "" {
+		updates.FixedAddress = optional(host.FixedAddress)
+	}
+	// updateHost reports a clash with any host other than target, so a row
+	// matching two different hosts fails instead of overwriting either
+	return updateHost(f, target, updates)
+}
+
+func parseImport(format string, data []byte) ([]importRecord, error) {
+	switch format {
+	case "json":
+		var hosts []models.Host
+		if err := json.Unmarshal(data, &hosts); err != nil {
+			return nil, &FieldError{"body", "invalid JSON host list: " + err.Error()}
+		}
+		records := make([]importRecord, len(hosts))
+		for i, host := range hosts {
+			records[i].host = host
+		}
+		return records, nil
+	case "csv":
+		return parseImportCSV(data)
+	}
+	return nil, &FieldError{"format", fmt.Sprintf("unsupported format %q, use csv or json", format)}
+}
+
+func parseImportCSV(data []byte) ([]importRecord, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1 // short rows are reported per row
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, &FieldError{"body", "missing CSV header row"}
+	}
+	columns := make([]func(h *models.Host) *string, len(header))
+	for i, name := range header {
+		if name == subnetColumn {
+			columns[i] = func(h *models.Host) *string { return &h.Subnet }
+			continue
+		}
+		idx := slices.IndexFunc(hostColumns, func(c struct {
+			name  string
+			field func(h *models.Host) *string
+		}) bool {
+			return c.name == name
+		})
+		if idx < 0 {
+			return nil, &FieldError{"body", fmt.Sprintf("unknown CSV column %q", name)}
+		}
+		columns[i] = hostColumns[idx].field
+	}
+
+	var records []importRecord
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var rec importRecord
+		switch {
+		case err != nil:
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rec.err = err
+		case len(record) != len(header):
+			rec.err = fmt.Errorf("expected %d columns, got %d", len(header), len(record))
+		default:
+			for i, value := range record {
+				*columns[i](&rec.host) = value
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+const importDhcpConf = `group lab {
+    option routers 10.0.0.1;
+    host web {
+        hardware ethernet 00:11:22:33:44:55;
+        fixed-address 10.0.0.5;
+        option domain-name "web.lab";
+    }
+}
+`
+
+// importCSV clashes with web in its first row and adds new1 in its second
+const importCSV = `name,hardware_ethernet,fixed_address
+web,00:11:22:33:44:55,10.0.0.50
+new1,00:11:22:33:44:66,10.0.0.6
+`
+
+func TestImportHosts(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		onConflict string
+		dryRun     bool
+		statuses   []string
+		report     models.ImportReport // counts only
+		written    bool
+	}{
+		{"skip", importCSV, "skip", false, []string{"skipped", "added"}, models.ImportReport{Added: 1, Skipped: 1}, true},
+		{"overwrite", importCSV, "overwrite", false, []string{"overwritten", "added"}, models.ImportReport{Added: 1, Overwritten: 1}, true},
+		{"fail", importCSV, "fail", false, []string{"error", "added"}, models.ImportReport{Added: 1, Failed: 1}, false},
+		{"dry run", importCSV, "overwrite", true, []string{"overwritten", "added"}, models.ImportReport{Added: 1, Overwritten: 1}, false},
+		{"row repeats an earlier row", importCSV + "new2,00:11:22:33:44:66,10.0.0.7\n", "overwrite", false,
+			[]string{"overwritten", "added", "error"}, models.ImportReport{Added: 1, Overwritten: 1, Failed: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useDhcpConf(t, importDhcpConf)
+
+			report, err := ImportHosts("csv", []byte(tt.data), tt.onConflict, tt.dryRun)
+			var importErr *ImportError
+			if tt.report.Failed > 0 != errors.As(err, &importErr) || (tt.report.Failed == 0 && err != nil) {
+				t.Fatalf("ImportHosts error = %v", err)
+			}
+
+			var statuses []string
+			for i, row := range report.Rows {
+				statuses = append(statuses, row.Status)
+				if row.Row != i+1 {
+					t.Errorf("row %d numbered %d", i+1, row.Row)
+				}
+			}
+			if !slices.Equal(statuses, tt.statuses) {
+				t.Errorf("statuses = %v, want %v", statuses, tt.statuses)
+			}
+			if report.DryRun != tt.dryRun || report.Added != tt.report.Added || report.Overwritten != tt.report.Overwritten ||
+				report.Skipped != tt.report.Skipped || report.Failed != tt.report.Failed {
+				t.Errorf("report = %+v", report)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.written {
+				if string(content) != importDhcpConf {
+					t.Errorf("dhcpd.conf changed:\n%s", content)
+				}
+				return
+			}
+			if !strings.Contains(string(content), "host new1 {") {
+				t.Errorf("new1 was not added:\n%s", content)
+			}
+			web, err := GetHost("web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantAddress := "10.0.0.5"
+			if tt.onConflict == "overwrite" {
+				wantAddress = "10.0.0.50"
+			}
+			if web.FixedAddress != wantAddress || web.Group != "lab" || len(web.Options) != 1 {
+				t.Errorf("web = %+v, want address %s, still in lab with its option", web, wantAddress)
+			}
+		})
+	}
+}
+
+func TestImportHostsRowErrors(t *testing.T) {
+	useDhcpConf(t, importDhcpConf)
+
+	data := importCSV + "new2,00:11:22:33:44:66,10.0.0.7\n"
+	report, _ := ImportHosts("csv", []byte(data), "skip", false)
+	row := report.Rows[2]
+	if row.Status != "error" || row.Field != "hardware_ethernet" || !strings.Contains(row.Error, "already used by row 2 of this import") {
+		t.Errorf("row 3 = %+v, want a clash with row 2", row)
+	}
+
+	if _, err := ImportHosts("csv", []byte(importCSV), "replace", false); err == nil {
+		t.Error("ImportHosts accepted an unknown on_conflict policy")
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// FieldError reports an invalid value in a request. Field is the JSON name
+// of the offending field.
+type FieldError struct {
+	Field string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+// dhcpd accepts unquoted host and group names made of letters, digits,
+// hyphens, underscores and dots
+var identifierRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// hostnameRegex matches DNS names, for next-server
+var hostnameRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+func validateIdentifier(field, value string) error {
+	if len(value) > 253 || !identifierRegex.MatchString(value) {
+		return &FieldError{field, fmt.Sprintf("invalid name %q: use letters, digits, '-', '_' and '.'", value)}
+	}
+	return nil
+}
+
+// normalizeMAC accepts colon, hyphen and Cisco dotted forms in any case and
+// returns the lowercase colon form dhcpd writes
+func normalizeMAC(field, value string) (string, error) {
+	hw, err := net.ParseMAC(strings.TrimSpace(value))
+	if err != nil || len(hw) != 6 {
+		return "", &FieldError{field, fmt.Sprintf("invalid MAC address %q", value)}
+	}
+	return hw.String(), nil
+}
+
+// normalizeIPv4List checks a comma-separated list of IPv4 addresses and
+// returns it in the `a, b` form dhcpd writes
+func normalizeIPv4List(field, value string) (string, error) {
+	var addrs []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if ip := net.ParseIP(part); ip == nil || ip.To4() == nil || strings.Contains(part, ":") {
+			return "", &FieldError{field, fmt.Sprintf("invalid IPv4 address %q", part)}
+		}
+		addrs = append(addrs, part)
+	}
+	return strings.Join(addrs, ", "), nil
+}
+
+func validateNetmask(field, value string) error {
+	ip := net.ParseIP(value)
+	if ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+		return &FieldError{field, fmt.Sprintf("invalid netmask %q", value)}
+	}
+	if _, bits := net.IPMask(ip.To4()).Size(); bits == 0 {
+		return &FieldError{field, fmt.Sprintf("netmask %q is not contiguous", value)}
+	}
+	return nil
+}
+
+func validateNextServer(field, value string) error {
+	if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+		return nil
+	}
+	if len(value) > 253 || !hostnameRegex.MatchString(value) {
+		return &FieldError{field, fmt.Sprintf("invalid address or host name %q", value)}
+	}
+	return nil
+}
+
+// hostFieldChecks validates and normalizes the address fields shared by
+// models.Host and models.HostUpdate. Empty values are left alone; whether
+// they are allowed is up to the caller.
+var hostFieldChecks = []struct {
+	field  string
+	value  func(h *models.Host) *string
+	update func(u *models.HostUpdate) *string
+	check  func(field, value string) (string, error)
+}{
+	{
+		"hardware_ethernet",
+		func(h *models.Host) *string { return &h.HardwareEthernet },
+		func(u *models.HostUpdate) *string { return u.HardwareEthernet },
+		normalizeMAC,
+	},
+	{
+		"fixed_address",
+		func(h *models.Host) *string { return &h.FixedAddress },
+		func(u *models.HostUpdate) *string { return u.FixedAddress },
+		normalizeIPv4List,
+	},
+	{
+		"option_routers",
+		func(h *models.Host) *string { return &h.OptionRouters },
+		func(u *models.HostUpdate) *string { return u.OptionRouters },
+		normalizeIPv4List,
+	},
+	{
+		"option_subnet_mask",
+		func(h *models.Host) *string { return &h.OptionSubnetMask },
+		func(u *models.HostUpdate) *string { return u.OptionSubnetMask },
+		func(field, value string) (string, error) { return value, validateNetmask(field, value) },
+	},
+	{
+		"option_domain_name_servers",
+		func(h *models.Host) *string { return &h.OptionDomainNameServers },
+		func(u *models.HostUpdate) *string { return u.OptionDomainNameServers },
+		normalizeIPv4List,
+	},
+}
+
+// validateHost checks a new host and normalizes its MAC and address lists
+// in place
+func validateHost(host *models.Host) error {
+	if err := validateIdentifier("name", host.Name); err != nil {
+		return err
+	}
+	for _, c := range hostFieldChecks {
+		value := c.value(host)
+		if *value == "" {
+			continue
+		}
+		normalized, err := c.check(c.field, *value)
+		if err != nil {
+			return err
+		}
+		*value = normalized
+	}
+	if host.Boot != nil {
+		return validateHostBoot(host.Boot)
+	}
+	return nil
+}
+
+// validateHostUpdate is validateHost for the fields present in an update
+func validateHostUpdate(updates *models.HostUpdate) error {
+	if updates.Name != nil && *updates.Name != "" {
+		if err := validateIdentifier("name", *updates.Name); err != nil {
+			return err
+		}
+	}
+	for _, c := range hostFieldChecks {
+		value := c.update(updates)
+		if value == nil || *value == "" {
+			continue
+		}
+		normalized, err := c.check(c.field, *value)
+		if err != nil {
+			return err
+		}
+		*value = normalized
+	}
+	if updates.Boot != nil {
+		return validateHostBoot(updates.Boot)
+	}
+	return nil
+}
+
+func validateHostBoot(boot *models.HostBoot) error {
+	if boot.NextServer != "" {
+		if err := validateNextServer("boot.next_server", boot.NextServer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
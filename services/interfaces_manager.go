@@ -2,6 +2,7 @@ package services
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -46,16 +47,34 @@ func GetInterfaces() (map[string]string, error) {
 }
 
 func SaveInterfaces(interfaces map[string]string) error {
+	return withFileLock(config.AppConfig.InterfacesConfPath, func() error {
+		return saveInterfaces(interfaces)
+	})
+}
+
+// saveInterfaces rewrites the INTERFACESv4/v6 lines. The caller must hold
+// the file's lock.
+func saveInterfaces(interfaces map[string]string) error {
+	content, err := interfacesContent(interfaces)
+	if err != nil {
+		return err
+	}
+	return replaceConfFile(interfacesFile(), content)
+}
+
+// interfacesContent returns the interfaces file with its INTERFACESv4/v6
+// lines set to interfaces
+func interfacesContent(interfaces map[string]string) ([]byte, error) {
 	filePath := config.AppConfig.InterfacesConfPath
 
 	file, err := os.Open(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("Error: interfaces config file %s does not exist. Cannot save.", filePath)
-			return fmt.Errorf("interfaces config file '%s' does not exist: %w", filePath, err)
+			return nil, fmt.Errorf("interfaces config file '%s' does not exist: %w", filePath, err)
 		}
 		log.Printf("Error opening interfaces config file for read: %v", err)
-		return fmt.Errorf("failed to open interfaces config file '%s' for read: %w", filePath, err)
+		return nil, fmt.Errorf("failed to open interfaces config file '%s' for read: %w", filePath, err)
 	}
 
 	scanner := bufio.NewScanner(file)
@@ -78,28 +97,52 @@ func SaveInterfaces(interfaces map[string]string) error {
 
 	if err := scanner.Err(); err != nil {
 		log.Printf("Error scanning interfaces config file '%s': %v", filePath, err)
-		return fmt.Errorf("failed to scan interfaces config file '%s': %w", filePath, err)
+		return nil, fmt.Errorf("failed to scan interfaces config file '%s': %w", filePath, err)
 	}
 
 	outputContent := strings.Join(newLines, "\n")
 	if len(newLines) > 0 && !strings.HasSuffix(outputContent, "\n") {
 		outputContent += "\n"
 	}
+	return []byte(outputContent), nil
+}
 
-	if err := os.WriteFile(filePath, []byte(outputContent), 0644); err != nil {
-		log.Printf("Error writing updated interfaces config to file '%s': %v", filePath, err)
-		return fmt.Errorf("failed to write updated interfaces config to '%s': %w", filePath, err)
-	}
-
-	return nil
+// editInterfaces loads the interface lists, applies edit and saves the
+// result, all under the file's lock. cond is checked against the lists
+// as served by GET /interfaces. Nothing is written if edit fails or
+// returns errUnchanged; the result reports whether the file was written.
+func editInterfaces(cond Precondition, edit func(interfaces map[string]string) error) (bool, error) {
+	changed := false
+	err := withFileLock(config.AppConfig.InterfacesConfPath, func() error {
+		interfaces, err := GetInterfaces()
+		if err != nil {
+			return err
+		}
+		if err := cond.check(ETag(interfaces)); err != nil {
+			return err
+		}
+		if err := edit(interfaces); err != nil {
+			if errors.Is(err, errUnchanged) {
+				return nil
+			}
+			return err
+		}
+		if err := saveInterfaces(interfaces); err != nil {
+			return err
+		}
+		changed = true
+		return nil
+	})
+	return changed, err
 }
 
-func AddInterface(ifaceType string, ifaceName string) error {
-	interfaces, err := GetInterfaces()
-	if err != nil {
-		return err
-	}
+func AddInterface(ifaceType string, ifaceName string, cond Precondition) (bool, error) {
+	return editInterfaces(cond, func(interfaces map[string]string) error {
+		return addInterface(interfaces, ifaceType, ifaceName)
+	})
+}
 
+func addInterface(interfaces map[string]string, ifaceType string, ifaceName string) error {
 	key := strings.ToLower(ifaceType)
 	if key != "v4" && key != "v6" {
 		return fmt.Errorf("invalid interface type: %s, must be v4 or v6", ifaceType)
@@ -111,22 +154,22 @@ func AddInterface(ifaceType string, ifaceName string) error {
 	for _, existingIface := range currentList {
 		if existingIface == ifaceName {
 			log.Printf("Interface %s already present in %s", ifaceName, key)
-			return nil
+			return errUnchanged
 		}
 	}
 
 	currentList = append(currentList, ifaceName)
 	interfaces[key] = strings.Join(currentList, " ")
-
-	return SaveInterfaces(interfaces)
+	return nil
 }
 
-func DeleteInterface(ifaceType string, ifaceName string) error {
-	interfaces, err := GetInterfaces()
-	if err != nil {
-		return err
-	}
+func DeleteInterface(ifaceType string, ifaceName string, cond Precondition) (bool, error) {
+	return editInterfaces(cond, func(interfaces map[string]string) error {
+		return deleteInterface(interfaces, ifaceType, ifaceName)
+	})
+}
 
+func deleteInterface(interfaces map[string]string, ifaceType string, ifaceName string) error {
 	key := strings.ToLower(ifaceType)
 	if key != "v4" && key != "v6" {
 		return fmt.Errorf("invalid interface type: %s, must be v4 or v6", ifaceType)
@@ -147,10 +190,9 @@ func DeleteInterface(ifaceType string, ifaceName string) error {
 
 	if !found {
 		log.Printf("Interface %s not found in %s, no action needed", ifaceName, key)
-		return nil
+		return errUnchanged
 	}
 
 	interfaces[key] = strings.Join(newList, " ")
-
-	return SaveInterfaces(interfaces)
+	return nil
 }
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// autoAddress is the fixed_address value that asks for allocation
+const autoAddress = "auto"
+
+// addrSpan is an inclusive range of IPv4 addresses
+type addrSpan struct {
+	start, end uint32
+}
+
+// allocateAddress picks the lowest free address for a host that asked for
+// one with `fixed_address: auto` or a subnet CIDR. It must run inside
+// editDhcpConf so that concurrent allocations see each other's hosts.
+func allocateAddress(f *dhcpconf.File, host *models.Host) error {
+	var subnets []*dhcpconf.Block
+	if host.Subnet != "" {
+		subnet, err := subnetForCIDR(f, host.Subnet)
+		if err != nil {
+			return err
+		}
+		subnets = append(subnets, subnet)
+	} else {
+		// Most specific first; catch-all 0.0.0.0/0 declarations only exist
+		// to make dhcpd listen and are never allocated from
+		for _, b := range subnetBlocks(f) {
+			if b.Args[3] != "0.0.0.0" {
+				subnets = append(subnets, b)
+			}
+		}
+		sort.SliceStable(subnets, func(i, j int) bool { return subnetSize(subnets[i]) < subnetSize(subnets[j]) })
+	}
+
+	excluded, err := allocationExclusions(f)
+	if err != nil {
+		return err
+	}
+	for _, subnet := range subnets {
+		if addr, ok := lowestFree(subnet, excluded); ok {
+			host.FixedAddress = uintToIPv4(addr)
+			return nil
+		}
+	}
+	if host.Subnet != "" {
+		return &FieldError{"subnet", fmt.Sprintf("no free address left in %s", host.Subnet)}
+	}
+	return &FieldError{"fixed_address", "no free address left in any subnet"}
+}
+
+// subnetForCIDR finds the subnet declaration matching a CIDR such as
+// 10.0.0.0/24
+func subnetForCIDR(f *dhcpconf.File, cidr string) (*dhcpconf.Block, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil || network.IP.To4() == nil {
+		return nil, &FieldError{"subnet", fmt.Sprintf("invalid subnet %q, expected CIDR notation", cidr)}
+	}
+	b := findSubnet(f, network.IP.String())
+	if b == nil || b.Args[3] != net.IP(network.Mask).String() {
+		return nil, &FieldError{"subnet", fmt.Sprintf("subnet %s is not declared", cidr)}
+	}
+	return b, nil
+}
+
+// lowestFree returns the lowest host address of subnet that is not in
+// excluded, not the network or broadcast address, not a router and not
+// inside one of the subnet's dynamic ranges
+func lowestFree(subnet *dhcpconf.Block, excluded []addrSpan) (uint32, bool) {
+	first, last, err := subnetBounds(subnet)
+	if err != nil || last-first < 2 {
+		return 0, false
+	}
+
+	spans := append([]addrSpan(nil), excluded...)
+	for _, s := range rangeStatements(subnet) {
+		r, _ := rangeFromStatement(s)
+		if start, end, err := rangeBounds(r); err == nil {
+			spans = append(spans, addrSpan{start, end})
+		}
+	}
+	for _, router := range splitAddresses(getParam(subnet, "option", "routers")) {
+		if addr, err := ipv4ToUint(router); err == nil {
+			spans = append(spans, addrSpan{addr, addr})
+		}
+	}
+
+	for addr := first + 1; addr < last; addr++ {
+		blocked := false
+		for _, span := range spans {
+			if addr >= span.start && addr <= span.end {
+				blocked = true
+				if span.end >= last {
+					return 0, false
+				}
+				addr = span.end // skip straight past the span
+				break
+			}
+		}
+		if !blocked {
+			return addr, true
+		}
+	}
+	return 0, false
+}
+
+// allocationExclusions collects the addresses no subnet may hand out:
+// existing reservations, active leases and ALLOCATION_EXCLUDE
+func allocationExclusions(f *dhcpconf.File) ([]addrSpan, error) {
+	var spans []addrSpan
+	for _, host := range listHosts(f) {
+		for _, a := range splitAddresses(host.FixedAddress) {
+			if addr, err := ipv4ToUint(a); err == nil {
+				spans = append(spans, addrSpan{addr, addr})
+			}
+		}
+	}
+
+	leases, err := readLeases()
+	if err != nil {
+		return nil, err
+	}
+	for _, lease := range leases {
+		if lease.BindingState != "active" {
+			continue
+		}
+		if addr, err := ipv4ToUint(lease.IP); err == nil {
+			spans = append(spans, addrSpan{addr, addr})
+		}
+	}
+
+	for _, entry := range strings.Split(config.AppConfig.AllocationExclude, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		span, err := parseExclusion(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALLOCATION_EXCLUDE entry %q: %w", entry, err)
+		}
+		spans = append(spans, span)
+	}
+	return spans, nil
+}
+
+// parseExclusion accepts a single address, a start-end range or a CIDR
+func parseExclusion(entry string) (addrSpan, error) {
+	if _, network, err := net.ParseCIDR(entry); err == nil {
+		start, err := ipv4ToUint(network.IP.String())
+		if err != nil {
+			return addrSpan{}, err
+		}
+		mask, err := ipv4ToUint(net.IP(network.Mask).String())
+		if err != nil {
+			return addrSpan{}, err
+		}
+		return addrSpan{start, start | ^mask}, nil
+	}
+	from, to, isRange := strings.Cut(entry, "-")
+	if !isRange {
+		to = from
+	}
+	start, end, err := rangeBounds(models.Range{Start: strings.TrimSpace(from), End: strings.TrimSpace(to)})
+	if err != nil {
+		return addrSpan{}, err
+	}
+	return addrSpan{start, end}, nil
+}
+
+// splitAddresses splits a dhcpd address list such as `10.0.0.1, 10.0.0.2`
+func splitAddresses(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+func TestConcurrentAutoAllocation(t *testing.T) {
+	// .1 is the router and .2-.3 are excluded, leaving .4-.14
+	useDhcpConf(t, "subnet 10.0.0.0 netmask 255.255.255.240 {\n    option routers 10.0.0.1;\n}\n")
+	config.AppConfig.AllocationExclude = "10.0.0.2-10.0.0.3"
+	const free = 11
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		addresses = map[string]string{}
+		exhausted int
+	)
+	for i := 0; i < free+1; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("auto%02d", i)
+			added, err := AddHost(models.Host{
+				Name:             name,
+				HardwareEthernet: fmt.Sprintf("00:11:22:33:44:%02x", i),
+				Subnet:           "10.0.0.0/28",
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			var fieldErr *FieldError
+			switch {
+			case errors.As(err, &fieldErr) && fieldErr.Field == "subnet":
+				exhausted++
+			case err != nil:
+				t.Errorf("AddHost %s: %v", name, err)
+			default:
+				if other, ok := addresses[added.FixedAddress]; ok {
+					t.Errorf("%s and %s were both given %s", name, other, added.FixedAddress)
+				}
+				addresses[added.FixedAddress] = name
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(addresses) != free || exhausted != 1 {
+		t.Errorf("allocated %d addresses with %d failures, want %d and 1: %v", len(addresses), exhausted, free, addresses)
+	}
+	for _, reserved := range []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.15"} {
+		if name, ok := addresses[reserved]; ok {
+			t.Errorf("%s was given %s", name, reserved)
+		}
+	}
+}
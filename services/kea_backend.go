@@ -0,0 +1,681 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// keaConfFile is the Kea DHCPv4 server configuration
+func keaConfFile() confFile {
+	return confFile{"kea-dhcp4.conf", config.AppConfig.KeaConfPath, config.AppConfig.CheckCommand}
+}
+
+// keaBackend manages host reservations and interfaces in kea-dhcp4.conf.
+// Reservations are placed in the most specific subnet4 containing their
+// address. The file is rewritten as JSON indented by four spaces with its
+// keys in their original order; files with comments, which would be lost,
+// or <?include?> directives are not edited.
+type keaBackend struct{}
+
+// keaConfig is a decoded kea-dhcp4.conf. Unknown keys are kept as they are.
+type keaConfig struct {
+	root     map[string]any
+	order    keyOrder
+	comments bool // the source had comments, which bytes cannot reproduce
+}
+
+// keaSubnet is a subnet4 entry, or the global scope when network is nil.
+// index is its position in keaConfig.subnets.
+type keaSubnet struct {
+	index         int
+	m             map[string]any
+	network       *net.IPNet
+	sharedNetwork string
+}
+
+// keaReservationRef locates a reservation within its subnet
+type keaReservationRef struct {
+	subnet *keaSubnet
+	index  int
+	res    map[string]any
+}
+
+func loadKeaConfig() (*keaConfig, error) {
+	path := config.AppConfig.KeaConfPath
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Error reading Kea config file %s: %v", path, err)
+		return nil, fmt.Errorf("failed to read Kea config: %w", err)
+	}
+
+	if bytes.Contains(content, []byte("<?include")) {
+		return nil, fmt.Errorf("%s uses <?include?>, which the kea backend does not support", path)
+	}
+	stripped := stripJSONComments(content)
+	root, order, err := decodeKeaJSON(stripped)
+	if err != nil {
+		log.Printf("Error parsing Kea config file %s: %v", path, err)
+		return nil, fmt.Errorf("failed to parse Kea config: %w", err)
+	}
+	if _, ok := root["Dhcp4"].(map[string]any); !ok {
+		return nil, fmt.Errorf("failed to parse Kea config: no Dhcp4 object in %s", path)
+	}
+	return &keaConfig{root: root, order: order, comments: !bytes.Equal(stripped, content)}, nil
+}
+
+func (c *keaConfig) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.order.encode(&buf, c.root, ""); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// editKeaConfig is editConfFile for kea-dhcp4.conf
+func editKeaConfig(edit func(c *keaConfig) error) error {
+	conf := keaConfFile()
+	return withFileLock(conf.path, func() error {
+		c, err := loadKeaConfig()
+		if err != nil {
+			return err
+		}
+		if c.comments {
+			return fmt.Errorf("%s contains comments, which would be lost; remove them to manage it through the API", conf.path)
+		}
+		if err := edit(c); err != nil {
+			if errors.Is(err, errUnchanged) {
+				return nil
+			}
+			return err
+		}
+		if config.AppConfig.Backend == "kea-ctrl" {
+			if err := testKeaConfig(c); err != nil {
+				return err
+			}
+		}
+		data, err := c.bytes()
+		if err != nil {
+			return fmt.Errorf("failed to encode Kea config: %w", err)
+		}
+		return replaceConfFile(conf, data)
+	})
+}
+
+func (c *keaConfig) dhcp4() map[string]any {
+	return c.root["Dhcp4"].(map[string]any)
+}
+
+// subnets returns every subnet4, including those inside shared networks,
+// followed by the global scope
+func (c *keaConfig) subnets() []*keaSubnet {
+	var subnets []*keaSubnet
+	add := func(list any, shared string) {
+		entries, _ := list.([]any)
+		for _, entry := range entries {
+			m, ok := entry.(map[string]any)
+			if !ok {
+				continue
+			}
+			prefix, _ := m["subnet"].(string)
+			if _, network, err := net.ParseCIDR(prefix); err == nil {
+				subnets = append(subnets, &keaSubnet{len(subnets), m, network, shared})
+			}
+		}
+	}
+	add(c.dhcp4()["subnet4"], "")
+	networks, _ := c.dhcp4()["shared-networks"].([]any)
+	for _, entry := range networks {
+		if m, ok := entry.(map[string]any); ok {
+			name, _ := m["name"].(string)
+			add(m["subnet4"], name)
+		}
+	}
+	return append(subnets, &keaSubnet{index: len(subnets), m: c.dhcp4()})
+}
+
+func (s *keaSubnet) reservations() []any {
+	list, _ := s.m["reservations"].([]any)
+	return list
+}
+
+func (c *keaConfig) reservations() []keaReservationRef {
+	var refs []keaReservationRef
+	for _, subnet := range c.subnets() {
+		for i, entry := range subnet.reservations() {
+			if res, ok := entry.(map[string]any); ok {
+				refs = append(refs, keaReservationRef{subnet, i, res})
+			}
+		}
+	}
+	return refs
+}
+
+func (c *keaConfig) hosts() []models.Host {
+	var hosts []models.Host
+	for _, ref := range c.reservations() {
+		hosts = append(hosts, hostFromKea(ref))
+	}
+	return hosts
+}
+
+func (c *keaConfig) findReservation(name string) *keaReservationRef {
+	for _, ref := range c.reservations() {
+		if hostname, _ := ref.res["hostname"].(string); hostname == name {
+			return &ref
+		}
+	}
+	return nil
+}
+
+// subnetFor returns the most specific subnet4 containing addr
+func (c *keaConfig) subnetFor(addr string) (*keaSubnet, error) {
+	ip := net.ParseIP(addr)
+	var best *keaSubnet
+	for _, subnet := range c.subnets() {
+		if subnet.network == nil || !subnet.network.Contains(ip) {
+			continue
+		}
+		if ones, _ := subnet.network.Mask.Size(); best == nil || ones > prefixLen(best.network) {
+			best = subnet
+		}
+	}
+	if best == nil {
+		return nil, &FieldError{"fixed_address", fmt.Sprintf("no subnet4 in %s contains %s", keaConfFile().name, addr)}
+	}
+	return best, nil
+}
+
+func prefixLen(n *net.IPNet) int {
+	ones, _ := n.Mask.Size()
+	return ones
+}
+
+// placeReservation stores res in the subnet containing its address. When
+// old is given the reservation replaces it, in place if the subnet is the
+// same.
+func (c *keaConfig) placeReservation(res map[string]any, old *keaReservationRef) error {
+	addr, _ := res["ip-address"].(string)
+	subnet, err := c.subnetFor(addr)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		c.order.inherit(res, old.res)
+		list := old.subnet.reservations()
+		if old.subnet.index == subnet.index {
+			list[old.index] = res
+			return nil
+		}
+		old.subnet.m["reservations"] = append(list[:old.index:old.index], list[old.index+1:]...)
+	}
+	subnet.m["reservations"] = append(subnet.reservations(), res)
+	return nil
+}
+
+// keaHostOptions maps option-data names to the dedicated models.Host fields
+var keaHostOptions = []struct {
+	name  string
+	field func(h *models.Host) *string
+}{
+	{"routers", func(h *models.Host) *string { return &h.OptionRouters }},
+	{"subnet-mask", func(h *models.Host) *string { return &h.OptionSubnetMask }},
+	{"domain-name-servers", func(h *models.Host) *string { return &h.OptionDomainNameServers }},
+}
+
+// keaBootOptions maps option-data names to models.HostBoot fields
+var keaBootOptions = []struct {
+	name  string
+	field func(b *models.HostBoot) *string
+}{
+	{"tftp-server-name", func(b *models.HostBoot) *string { return &b.TFTPServerName }},
+	{"boot-file-name", func(b *models.HostBoot) *string { return &b.BootfileName }},
+}
+
+func hostFromKea(ref keaReservationRef) models.Host {
+	res := ref.res
+	str := func(key string) string {
+		s, _ := res[key].(string)
+		return s
+	}
+	host := models.Host{
+		Name:             str("hostname"),
+		HardwareEthernet: str("hw-address"),
+		FixedAddress:     str("ip-address"),
+		SharedNetwork:    ref.subnet.sharedNetwork,
+	}
+	if mac, err := normalizeMAC("hardware_ethernet", host.HardwareEthernet); err == nil {
+		host.HardwareEthernet = mac
+	}
+
+	boot := models.HostBoot{NextServer: str("next-server"), Filename: str("boot-file-name")}
+	if boot.NextServer == "0.0.0.0" {
+		boot.NextServer = "" // how reservation-get-all reports an unset one
+	}
+	options, _ := res["option-data"].([]any)
+options:
+	for _, entry := range options {
+		opt, _ := entry.(map[string]any)
+		name, _ := opt["name"].(string)
+		data, _ := opt["data"].(string)
+		if name == "" {
+			continue
+		}
+		for _, o := range keaHostOptions {
+			if o.name == name {
+				if list, err := normalizeIPv4List(o.name, data); err == nil && o.name != "subnet-mask" {
+					data = list
+				}
+				*o.field(&host) = data
+				continue options
+			}
+		}
+		for _, o := range keaBootOptions {
+			if o.name == name {
+				*o.field(&boot) = data
+				continue options
+			}
+		}
+		host.Options.Set("option "+name, data)
+	}
+	if boot != (models.HostBoot{}) {
+		host.Boot = &boot
+	}
+	return host
+}
+
+// keaReservation renders host as a reservation. Keys and option-data
+// entries of old that the API does not manage are kept.
+func keaReservation(host models.Host, old map[string]any) (map[string]any, error) {
+	res := maps.Clone(old)
+	if res == nil {
+		res = map[string]any{}
+	}
+	set := func(key, value string) {
+		if value == "" {
+			delete(res, key)
+		} else {
+			res[key] = value
+		}
+	}
+	var boot models.HostBoot
+	if host.Boot != nil {
+		boot = *host.Boot
+	}
+	set("hostname", host.Name)
+	set("hw-address", host.HardwareEthernet)
+	set("ip-address", host.FixedAddress)
+	set("next-server", boot.NextServer)
+	set("boot-file-name", boot.Filename)
+
+	type option struct{ name, data string }
+	var wanted []option
+	for _, o := range keaHostOptions {
+		if value := *o.field(&host); value != "" {
+			wanted = append(wanted, option{o.name, strings.ReplaceAll(value, ", ", ",")})
+		}
+	}
+	for _, o := range keaBootOptions {
+		if value := *o.field(&boot); value != "" {
+			wanted = append(wanted, option{o.name, value})
+		}
+	}
+	for _, opt := range host.Options {
+		name, ok := strings.CutPrefix(opt.Name, "option ")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, &FieldError{"options." + opt.Name, `the kea backend only supports DHCP options, named "option <name>"`}
+		}
+		wanted = append(wanted, option{strings.TrimSpace(name), opt.Value})
+	}
+
+	data := map[string]string{}
+	for _, o := range wanted {
+		data[o.name] = o.data
+	}
+	// options already in the file keep their place, new ones go last
+	var optionData []any
+	oldOptions, _ := res["option-data"].([]any)
+	for _, entry := range oldOptions {
+		opt, _ := entry.(map[string]any)
+		name, _ := opt["name"].(string)
+		if name == "" {
+			optionData = append(optionData, entry)
+			continue
+		}
+		value, ok := data[name]
+		if !ok {
+			continue
+		}
+		opt = maps.Clone(opt)
+		opt["data"] = value
+		optionData = append(optionData, opt)
+		delete(data, name)
+	}
+	for _, o := range wanted {
+		if value, ok := data[o.name]; ok {
+			optionData = append(optionData, map[string]any{"name": o.name, "data": value})
+			delete(data, o.name)
+		}
+	}
+	if len(optionData) == 0 {
+		delete(res, "option-data")
+	} else {
+		res["option-data"] = optionData
+	}
+	return res, nil
+}
+
+func (keaBackend) ListHosts(query models.HostQuery) ([]models.Host, int, error) {
+	c, err := loadKeaConfig()
+	if err != nil {
+		return nil, 0, err
+	}
+	return queryHosts(c.hosts(), query)
+}
+
+func (keaBackend) GetHost(name string) (models.Host, error) {
+	c, err := loadKeaConfig()
+	if err != nil {
+		return models.Host{}, err
+	}
+	return findHostIn(c.hosts(), name)
+}
+
+func (keaBackend) AddHost(host models.Host) (added models.Host, err error) {
+	err = editKeaConfig(func(c *keaConfig) error {
+		if err := checkPortableHost(&host, "kea"); err != nil {
+			return err
+		}
+		if err := hostConflictIn(c.hosts(), host, ""); err != nil {
+			return err
+		}
+		res, err := keaReservation(host, nil)
+		if err != nil {
+			return err
+		}
+		if err := c.placeReservation(res, nil); err != nil {
+			return err
+		}
+		added, err = findHostIn(c.hosts(), host.Name)
+		return err
+	})
+	return added, err
+}
+
+func (keaBackend) UpdateHost(name string, updates models.HostUpdate, cond Precondition) (before, after models.Host, err error) {
+	err = editKeaConfig(func(c *keaConfig) error {
+		hosts := c.hosts()
+		if err := cond.check(hostETagIn(hosts, name)); err != nil {
+			return err
+		}
+		before, _ = findHostIn(hosts, name)
+		if err := validateHostUpdate(&updates); err != nil {
+			return err
+		}
+		ref := c.findReservation(name)
+		if ref == nil {
+			log.Printf("Host %s not found for update.", name)
+			return fmt.Errorf("host %s %w", name, ErrNotFound)
+		}
+
+		host := hostFromKea(*ref)
+		mergeHostUpdate(&host, updates)
+		if err := checkPortableHost(&host, "kea"); err != nil {
+			return err
+		}
+		if err := hostConflictIn(hosts, host, name); err != nil {
+			return err
+		}
+		res, err := keaReservation(host, ref.res)
+		if err != nil {
+			return err
+		}
+		if err := c.placeReservation(res, ref); err != nil {
+			return err
+		}
+		after, err = findHostIn(c.hosts(), host.Name)
+		return err
+	})
+	return before, after, err
+}
+
+func (keaBackend) DeleteHost(name string, cond Precondition) (deleted models.Host, err error) {
+	err = editKeaConfig(func(c *keaConfig) error {
+		hosts := c.hosts()
+		if err := cond.check(hostETagIn(hosts, name)); err != nil {
+			return err
+		}
+		deleted, _ = findHostIn(hosts, name)
+		ref := c.findReservation(name)
+		if ref == nil {
+			log.Printf("Host %s not found for deletion.", name)
+			return errUnchanged // idempotent delete
+		}
+		list := ref.subnet.reservations()
+		ref.subnet.m["reservations"] = append(list[:ref.index:ref.index], list[ref.index+1:]...)
+		return nil
+	})
+	return deleted, err
+}
+
+// interfaces returns the interfaces-config list in the form served by GET
+// /interfaces. kea-dhcp4 only serves DHCPv4, so v6 is always empty.
+func (c *keaConfig) interfaces() map[string]string {
+	var names []string
+	ifaces, _ := c.dhcp4()["interfaces-config"].(map[string]any)
+	list, _ := ifaces["interfaces"].([]any)
+	for _, entry := range list {
+		if name, ok := entry.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return map[string]string{"v4": strings.Join(names, " "), "v6": ""}
+}
+
+func (keaBackend) GetInterfaces() (map[string]string, error) {
+	c, err := loadKeaConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.interfaces(), nil
+}
+
+// editKeaInterfaces is editInterfaces for interfaces-config
+func editKeaInterfaces(cond Precondition, edit func(interfaces map[string]string) error) (bool, error) {
+	changed := true
+	err := editKeaConfig(func(c *keaConfig) error {
+		interfaces := c.interfaces()
+		if err := cond.check(ETag(interfaces)); err != nil {
+			return err
+		}
+		if err := edit(interfaces); err != nil {
+			changed = !errors.Is(err, errUnchanged)
+			return err
+		}
+		if interfaces["v6"] != "" {
+			return &FieldError{"type", "the kea backend manages kea-dhcp4 and only takes v4 interfaces"}
+		}
+
+		list := []any{}
+		for _, name := range strings.Fields(interfaces["v4"]) {
+			list = append(list, name)
+		}
+		ifaces, _ := c.dhcp4()["interfaces-config"].(map[string]any)
+		if ifaces == nil {
+			ifaces = map[string]any{}
+			c.dhcp4()["interfaces-config"] = ifaces
+		}
+		ifaces["interfaces"] = list
+		return nil
+	})
+	return changed && err == nil, err
+}
+
+func (keaBackend) AddInterface(ifaceType, ifaceName string, cond Precondition) (bool, error) {
+	return editKeaInterfaces(cond, func(interfaces map[string]string) error {
+		return addInterface(interfaces, ifaceType, ifaceName)
+	})
+}
+
+func (keaBackend) DeleteInterface(ifaceType, ifaceName string, cond Precondition) (bool, error) {
+	return editKeaInterfaces(cond, func(interfaces map[string]string) error {
+		return deleteInterface(interfaces, ifaceType, ifaceName)
+	})
+}
+
+func (keaBackend) ListLeases(filter models.LeaseFilter) ([]models.Lease, error) {
+	leases, err := readKeaLeases()
+	if err != nil {
+		return nil, err
+	}
+	return filterLeases(leases, filter)
+}
+
+// readKeaLeases parses a memfile lease database. Like dhcpd.leases it is
+// appended to, so the last row for an address is the current one.
+func readKeaLeases() ([]models.Lease, error) {
+	path := config.AppConfig.LeasesPath
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Leases file not found at %s, returning no leases", path)
+			return nil, nil
+		}
+		log.Printf("Error reading leases file: %v", err)
+		return nil, fmt.Errorf("failed to read leases file: %w", err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse leases file: %w", err)
+	}
+	column := map[string]int{}
+	for i, name := range header {
+		column[name] = i
+	}
+
+	now := time.Now()
+	latest := make(map[string]models.Lease)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Error parsing leases file: %v", err)
+			return nil, fmt.Errorf("failed to parse leases file: %w", err)
+		}
+		field := func(name string) string {
+			if i, ok := column[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		lease := models.Lease{
+			IP:               field("address"),
+			HardwareEthernet: field("hwaddr"),
+			ClientHostname:   strings.ReplaceAll(field("hostname"), "&#x2c", ","),
+			UID:              field("client_id"),
+		}
+		expire, errExpire := strconv.ParseInt(field("expire"), 10, 64)
+		lifetime, _ := strconv.ParseInt(field("valid_lifetime"), 10, 64)
+		if errExpire == nil {
+			ends := time.Unix(expire, 0).UTC()
+			starts := ends.Add(-time.Duration(lifetime) * time.Second)
+			lease.Starts, lease.Ends = &starts, &ends
+		}
+		switch field("state") {
+		case "1":
+			lease.BindingState = "abandoned" // declined by the client
+		case "2":
+			lease.BindingState = "free" // expired and reclaimed
+		default:
+			lease.BindingState = "active"
+			if lifetime == 0 {
+				lease.BindingState = "released"
+			} else if lease.Ends != nil && lease.Ends.Before(now) {
+				lease.BindingState = "expired"
+			}
+		}
+		latest[lease.IP] = lease
+	}
+	return leasesByAddress(latest), nil
+}
+
+func (b keaBackend) ReserveLease(ip string, req models.LeaseReservation) (models.Host, error) {
+	leases, err := readKeaLeases()
+	if err != nil {
+		return models.Host{}, err
+	}
+	host, err := leaseHost(leases, ip, req)
+	if err != nil {
+		return models.Host{}, err
+	}
+	return b.AddHost(host)
+}
+
+func (keaBackend) ApplyChanges() models.ApplyResult { return ApplyChanges() }
+
+func (keaBackend) NotifyChanged() models.ApplyResult { return NotifyChanged() }
+
+func (keaBackend) HostsChanged() models.ApplyResult { return NotifyChanged() }
+
+// stripJSONComments blanks out the //, # and /* */ comments Kea allows in
+// its configuration, leaving strings untouched
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		switch {
+		case inString:
+			out = append(out, ch)
+			if ch == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if ch == '"' {
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+			out = append(out, ch)
+		case ch == '#' || (ch == '/' && i+1 < len(data) && data[i+1] == '/'):
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case ch == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+			out = append(out, ' ')
+		default:
+			out = append(out, ch)
+		}
+	}
+	return out
+}
@@ -0,0 +1,487 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+const keaControlTimeout = 10 * time.Second
+
+// Kea command result codes
+const (
+	keaResultSuccess     = 0
+	keaResultUnsupported = 2
+	keaResultEmpty       = 3
+)
+
+// keaCtrlMu serializes reservation changes so conflict checks see each
+// other's hosts
+var keaCtrlMu sync.Mutex
+
+// KeaCommandError is a command the Kea server answered with an error
+type KeaCommandError struct {
+	Command string
+	Result  int
+	Text    string
+}
+
+func (e *KeaCommandError) Error() string {
+	return fmt.Sprintf("kea %s failed (result %d): %s", e.Command, e.Result, e.Text)
+}
+
+// KeaRestoreError is an update that deleted the old reservation, failed
+// to add the new one and could not put the old one back either, so the
+// host is now missing from the server
+type KeaRestoreError struct {
+	Host       string
+	Err        error // why the new reservation was refused
+	RestoreErr error // why the old one could not be restored
+}
+
+func (e *KeaRestoreError) Error() string {
+	return fmt.Sprintf("reservation %s was deleted and could not be restored after the update failed (%v): %v", e.Host, e.Err, e.RestoreErr)
+}
+
+func (e *KeaRestoreError) Unwrap() error { return e.Err }
+
+type keaResponse struct {
+	Result    int             `json:"result"`
+	Text      string          `json:"text"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// keaCommand sends a command to Kea and returns the arguments of the
+// answer, nil when Kea reports an empty result. KEA_CONTROL_URL is either
+// the Control Agent's http(s) URL or unix:///path of the kea-dhcp4
+// control socket.
+func keaCommand(command string, arguments any) (json.RawMessage, error) {
+	target, err := url.Parse(config.AppConfig.KeaControlURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KEA_CONTROL_URL: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keaControlTimeout)
+	defer cancel()
+
+	var resp keaResponse
+	switch target.Scheme {
+	case "unix":
+		err = keaSocketCommand(ctx, target.Path, command, arguments, &resp)
+	case "http", "https":
+		err = keaHTTPCommand(ctx, target, command, arguments, &resp)
+	default:
+		err = fmt.Errorf("unsupported KEA_CONTROL_URL scheme %q, use http, https or unix", target.Scheme)
+	}
+	if err != nil {
+		log.Printf("Error sending %s to Kea: %v", command, err)
+		return nil, fmt.Errorf("failed to send %s to Kea: %w", command, err)
+	}
+
+	switch resp.Result {
+	case keaResultSuccess:
+		return resp.Arguments, nil
+	case keaResultEmpty:
+		return nil, nil
+	}
+	log.Printf("Kea rejected %s: %s", command, resp.Text)
+	return nil, &KeaCommandError{command, resp.Result, resp.Text}
+}
+
+// keaSocketCommand talks to the server's own control socket, which takes
+// a single command and answers with a single object
+func keaSocketCommand(ctx context.Context, path, command string, arguments any, resp *keaResponse) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	request := map[string]any{"command": command}
+	if arguments != nil {
+		request["arguments"] = arguments
+	}
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return err
+	}
+	return json.NewDecoder(conn).Decode(resp)
+}
+
+// keaHTTPCommand talks to the Control Agent, which forwards the command to
+// the dhcp4 service and answers with one result per service. Credentials
+// in the URL are sent as basic auth.
+func keaHTTPCommand(ctx context.Context, target *url.URL, command string, arguments any, resp *keaResponse) error {
+	request := map[string]any{"command": command, "service": []string{"dhcp4"}}
+	if arguments != nil {
+		request["arguments"] = arguments
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if target.User != nil {
+		password, _ := target.User.Password()
+		req.SetBasicAuth(target.User.Username(), password)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("control agent answered %s: %s", res.Status, strings.TrimSpace(string(data)))
+	}
+
+	var results []keaResponse
+	if err := json.Unmarshal(data, &results); err != nil {
+		// Errors of the agent itself come as a single object
+		return json.Unmarshal(data, resp)
+	}
+	if len(results) == 0 {
+		return errors.New("control agent sent an empty answer")
+	}
+	*resp = results[0]
+	return nil
+}
+
+// keaRunningConfig fetches the server's current configuration
+func keaRunningConfig() (*keaConfig, error) {
+	args, err := keaCommand("config-get", nil)
+	if err != nil {
+		return nil, err
+	}
+	root, order, err := decodeKeaJSON(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Kea config-get answer: %w", err)
+	}
+	if _, ok := root["Dhcp4"].(map[string]any); !ok {
+		return nil, errors.New("failed to read Kea config-get answer: no Dhcp4 object")
+	}
+	return &keaConfig{root: root, order: order}, nil
+}
+
+// testKeaConfig runs config-test on a candidate kea-dhcp4.conf; a rejected
+// configuration is a ValidationError like a failed syntax check
+func testKeaConfig(c *keaConfig) error {
+	_, err := keaCommand("config-test", c.root)
+	var cmdErr *KeaCommandError
+	if errors.As(err, &cmdErr) {
+		return &ValidationError{Lines: []string{cmdErr.Text}}
+	}
+	return err
+}
+
+// subnetID returns the Kea subnet-id of a subnet, 0 for the global scope
+func (s *keaSubnet) subnetID() (int64, error) {
+	if s.network == nil {
+		return 0, nil
+	}
+	id, ok := s.m["id"].(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("subnet %s has no id", s.network)
+	}
+	return id.Int64()
+}
+
+// keaCtrlBackend manages host reservations live through Kea's host_cmds
+// hook, which stores them in the server's hosts database. Interfaces are
+// still edited in kea-dhcp4.conf, checked with config-test, and applying
+// runs config-reload.
+type keaCtrlBackend struct {
+	keaBackend
+}
+
+// keaCtrlHost is a reservation as returned by reservation-get-all
+type keaCtrlHost struct {
+	ref      keaReservationRef
+	subnetID int64
+	host     models.Host
+}
+
+// reservations lists the reservations of every subnet and the global
+// scope of the running configuration
+func (keaCtrlBackend) reservations() (*keaConfig, []keaCtrlHost, error) {
+	c, err := keaRunningConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	var hosts []keaCtrlHost
+	for _, subnet := range c.subnets() {
+		id, err := subnet.subnetID()
+		if err != nil {
+			return nil, nil, err
+		}
+		args, err := keaCommand("reservation-get-all", map[string]any{"subnet-id": id})
+		if err != nil {
+			return nil, nil, err
+		}
+		if args == nil {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(args))
+		dec.UseNumber()
+		var answer struct {
+			Hosts []map[string]any `json:"hosts"`
+		}
+		if err := dec.Decode(&answer); err != nil {
+			return nil, nil, fmt.Errorf("failed to read Kea reservation-get-all answer: %w", err)
+		}
+		for _, res := range answer.Hosts {
+			ref := keaReservationRef{subnet: subnet, res: res}
+			hosts = append(hosts, keaCtrlHost{ref, id, hostFromKea(ref)})
+		}
+	}
+	return c, hosts, nil
+}
+
+func modelHosts(hosts []keaCtrlHost) []models.Host {
+	list := make([]models.Host, len(hosts))
+	for i, h := range hosts {
+		list[i] = h.host
+	}
+	return list
+}
+
+func (b keaCtrlBackend) ListHosts(query models.HostQuery) ([]models.Host, int, error) {
+	_, hosts, err := b.reservations()
+	if err != nil {
+		return nil, 0, err
+	}
+	return queryHosts(modelHosts(hosts), query)
+}
+
+func (b keaCtrlBackend) GetHost(name string) (models.Host, error) {
+	_, hosts, err := b.reservations()
+	if err != nil {
+		return models.Host{}, err
+	}
+	return findHostIn(modelHosts(hosts), name)
+}
+
+// renderKeaReservation renders host as a reservation, with the subnet-id
+// of the subnet containing its address
+func renderKeaReservation(c *keaConfig, host models.Host, old map[string]any) (keaReservationRef, int64, error) {
+	subnet, err := c.subnetFor(host.FixedAddress)
+	if err != nil {
+		return keaReservationRef{}, 0, err
+	}
+	id, err := subnet.subnetID()
+	if err != nil {
+		return keaReservationRef{}, 0, err
+	}
+	res, err := keaReservation(host, old)
+	if err != nil {
+		return keaReservationRef{}, 0, err
+	}
+	res["subnet-id"] = id
+	return keaReservationRef{subnet: subnet, res: res}, id, nil
+}
+
+// addKeaReservation sends host with reservation-add and returns it as Kea
+// now stores it
+func addKeaReservation(c *keaConfig, host models.Host, old map[string]any) (models.Host, error) {
+	ref, _, err := renderKeaReservation(c, host, old)
+	if err != nil {
+		return models.Host{}, err
+	}
+	if _, err := keaCommand("reservation-add", map[string]any{"reservation": ref.res}); err != nil {
+		return models.Host{}, err
+	}
+	return hostFromKea(ref), nil
+}
+
+// deleteKeaReservation removes a reservation by its hardware address, or
+// by its IP address if it has none
+func deleteKeaReservation(h keaCtrlHost) error {
+	args := map[string]any{"subnet-id": h.subnetID}
+	if mac, _ := h.ref.res["hw-address"].(string); mac != "" {
+		args["identifier-type"] = "hw-address"
+		args["identifier"] = mac
+	} else {
+		args["ip-address"] = h.host.FixedAddress
+	}
+	_, err := keaCommand("reservation-del", args)
+	return err
+}
+
+func findKeaCtrlHost(hosts []keaCtrlHost, name string) *keaCtrlHost {
+	for _, h := range hosts {
+		if h.host.Name == name {
+			return &h
+		}
+	}
+	return nil
+}
+
+func (b keaCtrlBackend) AddHost(host models.Host) (models.Host, error) {
+	keaCtrlMu.Lock()
+	defer keaCtrlMu.Unlock()
+
+	if err := checkPortableHost(&host, "kea"); err != nil {
+		return host, err
+	}
+	c, hosts, err := b.reservations()
+	if err != nil {
+		return host, err
+	}
+	if err := hostConflictIn(modelHosts(hosts), host, ""); err != nil {
+		return host, err
+	}
+	return addKeaReservation(c, host, nil)
+}
+
+// UpdateHost changes the reservation in place with reservation-update
+// when it keeps its hardware address and subnet and the hook supports the
+// command. Otherwise it replaces it with reservation-del and
+// reservation-add, putting the old one back if the new one is refused.
+func (b keaCtrlBackend) UpdateHost(name string, updates models.HostUpdate, cond Precondition) (before, after models.Host, err error) {
+	keaCtrlMu.Lock()
+	defer keaCtrlMu.Unlock()
+
+	c, hosts, err := b.reservations()
+	if err != nil {
+		return before, after, err
+	}
+	if err := cond.check(hostETagIn(modelHosts(hosts), name)); err != nil {
+		return before, after, err
+	}
+	if err := validateHostUpdate(&updates); err != nil {
+		return before, after, err
+	}
+	old := findKeaCtrlHost(hosts, name)
+	if old == nil {
+		log.Printf("Host %s not found for update.", name)
+		return before, after, fmt.Errorf("host %s %w", name, ErrNotFound)
+	}
+	before = old.host
+
+	host := old.host
+	mergeHostUpdate(&host, updates)
+	if err := checkPortableHost(&host, "kea"); err != nil {
+		return before, after, err
+	}
+	if err := hostConflictIn(modelHosts(hosts), host, name); err != nil {
+		return before, after, err
+	}
+	// Render the new reservation before touching the old one
+	ref, id, err := renderKeaReservation(c, host, old.ref.res)
+	if err != nil {
+		return before, after, err
+	}
+
+	if id == old.subnetID && host.HardwareEthernet != "" && host.HardwareEthernet == old.host.HardwareEthernet {
+		_, err := keaCommand("reservation-update", map[string]any{"reservation": ref.res})
+		var cmdErr *KeaCommandError
+		if !errors.As(err, &cmdErr) || cmdErr.Result != keaResultUnsupported {
+			if err != nil {
+				return before, after, err
+			}
+			return before, hostFromKea(ref), nil
+		}
+	}
+
+	if err := deleteKeaReservation(*old); err != nil {
+		return before, after, err
+	}
+	if _, err := keaCommand("reservation-add", map[string]any{"reservation": ref.res}); err != nil {
+		restore := maps.Clone(old.ref.res)
+		restore["subnet-id"] = old.subnetID
+		if _, restoreErr := keaCommand("reservation-add", map[string]any{"reservation": restore}); restoreErr != nil {
+			log.Printf("ERROR: could not restore reservation %s after failed update: %v", name, restoreErr)
+			return before, models.Host{}, &KeaRestoreError{Host: name, Err: err, RestoreErr: restoreErr}
+		}
+		return before, models.Host{}, err
+	}
+	return before, hostFromKea(ref), nil
+}
+
+func (b keaCtrlBackend) DeleteHost(name string, cond Precondition) (models.Host, error) {
+	keaCtrlMu.Lock()
+	defer keaCtrlMu.Unlock()
+
+	_, hosts, err := b.reservations()
+	if err != nil {
+		return models.Host{}, err
+	}
+	if err := cond.check(hostETagIn(modelHosts(hosts), name)); err != nil {
+		return models.Host{}, err
+	}
+	old := findKeaCtrlHost(hosts, name)
+	if old == nil {
+		log.Printf("Host %s not found for deletion.", name)
+		return models.Host{}, nil // idempotent delete
+	}
+	return old.host, deleteKeaReservation(*old)
+}
+
+func (b keaCtrlBackend) ReserveLease(ip string, req models.LeaseReservation) (models.Host, error) {
+	leases, err := readKeaLeases()
+	if err != nil {
+		return models.Host{}, err
+	}
+	host, err := leaseHost(leases, ip, req)
+	if err != nil {
+		return models.Host{}, err
+	}
+	return b.AddHost(host)
+}
+
+// ApplyChanges asks the server to reload kea-dhcp4.conf. Reservations
+// take effect as soon as they are added and do not need it.
+func (keaCtrlBackend) ApplyChanges() models.ApplyResult {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	result := models.ApplyResult{Mode: config.AppConfig.ApplyMode, Command: "config-reload"}
+	if _, err := keaCommand("config-reload", nil); err != nil {
+		result.Error = err.Error()
+		result.ExitCode = -1
+		var cmdErr *KeaCommandError
+		if errors.As(err, &cmdErr) {
+			result.ExitCode = cmdErr.Result
+			result.Output = cmdErr.Text
+		}
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+func (b keaCtrlBackend) NotifyChanged() models.ApplyResult {
+	return notifyChanged(b.ApplyChanges)
+}
+
+// HostsChanged reports success without a command: reservation-add and
+// reservation-del already changed the running server
+func (keaCtrlBackend) HostsChanged() models.ApplyResult {
+	return models.ApplyResult{Mode: config.AppConfig.ApplyMode, Success: true}
+}
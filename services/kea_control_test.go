@@ -0,0 +1,329 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// keaStub answers Kea commands with answer and records every request it
+// receives
+type keaStub struct {
+	mu       sync.Mutex
+	requests []map[string]any
+	answer   func(command string, args map[string]any) map[string]any
+}
+
+func (s *keaStub) handle(request map[string]any) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+	args, _ := request["arguments"].(map[string]any)
+	return s.answer(request["command"].(string), args)
+}
+
+// commands returns the commands received so far
+func (s *keaStub) commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var commands []string
+	for _, r := range s.requests {
+		commands = append(commands, r["command"].(string))
+	}
+	return commands
+}
+
+// setKeaControlURL points KEA_CONTROL_URL at url for the rest of the test
+func setKeaControlURL(t *testing.T, url string) {
+	t.Helper()
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+	config.AppConfig.KeaControlURL = url
+}
+
+// serveKeaSocket serves s on a unix socket like the kea-dhcp4 control
+// socket: one request and one answer per connection
+func serveKeaSocket(t *testing.T, s *keaStub) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kea4.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var request map[string]any
+			if err := json.NewDecoder(conn).Decode(&request); err == nil {
+				json.NewEncoder(conn).Encode(s.handle(request))
+			}
+			conn.Close()
+		}
+	}()
+	setKeaControlURL(t, "unix://"+path)
+}
+
+// serveKeaAgent serves s over HTTP like the Control Agent, which requires
+// basic auth and answers with one result per service
+func serveKeaAgent(t *testing.T, s *keaStub) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "api" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"result": 1, "text": "Unauthorized"}`))
+			return
+		}
+		var request map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if services, _ := request["service"].([]any); len(services) != 1 || services[0] != "dhcp4" {
+			t.Errorf("service = %v, want [dhcp4]", request["service"])
+		}
+		json.NewEncoder(w).Encode([]any{s.handle(request)})
+	}))
+	t.Cleanup(server.Close)
+	setKeaControlURL(t, strings.Replace(server.URL, "http://", "http://api:secret@", 1))
+}
+
+func TestKeaCommand(t *testing.T) {
+	transports := []struct {
+		name  string
+		serve func(*testing.T, *keaStub)
+	}{
+		{"socket", serveKeaSocket},
+		{"agent", serveKeaAgent},
+	}
+	for _, tr := range transports {
+		t.Run(tr.name, func(t *testing.T) {
+			stub := &keaStub{answer: func(command string, args map[string]any) map[string]any {
+				switch command {
+				case "version-get":
+					return map[string]any{"result": 0, "text": "2.4.1", "arguments": map[string]any{"extended": "2.4.1"}}
+				case "reservation-get-all":
+					return map[string]any{"result": 3, "text": "0 IPv4 host(s) found."}
+				}
+				return map[string]any{"result": 2, "text": "'" + command + "' command not supported."}
+			}}
+			tr.serve(t, stub)
+
+			args, err := keaCommand("version-get", map[string]any{"detail": true})
+			if err != nil {
+				t.Fatalf("version-get: %v", err)
+			}
+			if got := strings.Join(strings.Fields(string(args)), ""); got != `{"extended":"2.4.1"}` {
+				t.Errorf("version-get arguments = %s", args)
+			}
+
+			args, err = keaCommand("reservation-get-all", map[string]any{"subnet-id": 1})
+			if err != nil || args != nil {
+				t.Errorf("empty result = %s, %v; want nil, nil", args, err)
+			}
+
+			_, err = keaCommand("lease4-wipe", nil)
+			var cmdErr *KeaCommandError
+			if !errors.As(err, &cmdErr) {
+				t.Fatalf("error result = %v, want a KeaCommandError", err)
+			}
+			if cmdErr.Command != "lease4-wipe" || cmdErr.Result != 2 || cmdErr.Text != "'lease4-wipe' command not supported." {
+				t.Errorf("KeaCommandError = %+v", cmdErr)
+			}
+
+			stub.mu.Lock()
+			defer stub.mu.Unlock()
+			if detail := stub.requests[0]["arguments"].(map[string]any)["detail"]; detail != true {
+				t.Errorf("arguments sent = %v", stub.requests[0]["arguments"])
+			}
+			if _, ok := stub.requests[2]["arguments"]; ok {
+				t.Errorf("command without arguments sent %v", stub.requests[2]["arguments"])
+			}
+		})
+	}
+}
+
+func TestKeaCommandAgentErrors(t *testing.T) {
+	serveKeaAgent(t, &keaStub{})
+	config.AppConfig.KeaControlURL = strings.Replace(config.AppConfig.KeaControlURL, "secret", "wrong", 1)
+
+	_, err := keaCommand("config-get", nil)
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("error = %v, want the agent's 401", err)
+	}
+
+	config.AppConfig.KeaControlURL = "ftp://127.0.0.1/"
+	if _, err := keaCommand("config-get", nil); err == nil || !strings.Contains(err.Error(), "unsupported KEA_CONTROL_URL scheme") {
+		t.Errorf("error = %v, want an unsupported scheme", err)
+	}
+}
+
+// reservationStub serves a kea-ctrl server holding the reservation web
+// in subnet 1. reservation-update is answered only if update is set, and
+// reservation-add refuses the reservations refuse matches.
+func reservationStub(t *testing.T, update bool, refuse func(res map[string]any) bool) (*keaStub, *[]map[string]any) {
+	t.Helper()
+	reservations := []map[string]any{{
+		"subnet-id":  1,
+		"hw-address": "aa:bb:cc:00:00:10",
+		"ip-address": "10.0.0.10",
+		"hostname":   "web",
+		"option-data": []any{
+			map[string]any{"name": "domain-name-servers", "data": "1.1.1.1", "always-send": true},
+		},
+	}}
+	stub := &keaStub{answer: func(command string, args map[string]any) map[string]any {
+		switch command {
+		case "config-get":
+			return map[string]any{"result": 0, "arguments": map[string]any{
+				"Dhcp4": map[string]any{"subnet4": []any{map[string]any{"id": 1, "subnet": "10.0.0.0/24"}}},
+			}}
+		case "reservation-get-all":
+			if len(reservations) == 0 || args["subnet-id"] != float64(1) {
+				return map[string]any{"result": 3, "text": "0 IPv4 host(s) found."}
+			}
+			return map[string]any{"result": 0, "arguments": map[string]any{"count": len(reservations), "hosts": reservations}}
+		case "reservation-del":
+			reservations = nil
+			return map[string]any{"result": 0, "text": "Host deleted."}
+		case "reservation-add":
+			res := args["reservation"].(map[string]any)
+			if refuse(res) {
+				return map[string]any{"result": 1, "text": "Database duplicate entry error"}
+			}
+			reservations = append(reservations, res)
+			return map[string]any{"result": 0, "text": "Host added."}
+		case "reservation-update":
+			if update {
+				reservations = []map[string]any{args["reservation"].(map[string]any)}
+				return map[string]any{"result": 0, "text": "Host updated."}
+			}
+		}
+		return map[string]any{"result": 2, "text": "'" + command + "' command not supported."}
+	}}
+	serveKeaSocket(t, stub)
+	return stub, &reservations
+}
+
+func TestKeaCtrlUpdateHostInPlace(t *testing.T) {
+	stub, reservations := reservationStub(t, true, func(map[string]any) bool { return false })
+
+	address := "10.0.0.20"
+	_, after, err := keaCtrlBackend{}.UpdateHost("web", models.HostUpdate{FixedAddress: &address}, Precondition{})
+	if err != nil {
+		t.Fatalf("UpdateHost: %v", err)
+	}
+	if after.FixedAddress != "10.0.0.20" {
+		t.Errorf("after = %+v", after)
+	}
+	want := []string{"config-get", "reservation-get-all", "reservation-get-all", "reservation-update"}
+	if got := stub.commands(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if res := *reservations; len(res) != 1 || res[0]["ip-address"] != "10.0.0.20" || res[0]["subnet-id"] != float64(1) {
+		t.Errorf("reservations after update = %v", res)
+	}
+}
+
+func TestKeaCtrlUpdateHostRestoresReservation(t *testing.T) {
+	stub, reservations := reservationStub(t, false, func(res map[string]any) bool { return res["ip-address"] == "10.0.0.20" })
+
+	address := "10.0.0.20"
+	before, after, err := keaCtrlBackend{}.UpdateHost("web", models.HostUpdate{FixedAddress: &address}, Precondition{})
+	var cmdErr *KeaCommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Command != "reservation-add" {
+		t.Fatalf("UpdateHost error = %v, want the refused reservation-add", err)
+	}
+	if before.FixedAddress != "10.0.0.10" || after.Name != "" {
+		t.Errorf("before = %+v, after = %+v", before, after)
+	}
+
+	// reservation-get-all runs for the global scope and subnet 1, and the
+	// unsupported reservation-update falls back to del and add
+	want := []string{"config-get", "reservation-get-all", "reservation-get-all", "reservation-update", "reservation-del", "reservation-add", "reservation-add"}
+	if got := stub.commands(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("commands = %v, want %v", got, want)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	del := stub.requests[4]["arguments"].(map[string]any)
+	if del["subnet-id"] != float64(1) || del["identifier-type"] != "hw-address" || del["identifier"] != "aa:bb:cc:00:00:10" {
+		t.Errorf("reservation-del arguments = %v", del)
+	}
+	restore := stub.requests[6]["arguments"].(map[string]any)["reservation"].(map[string]any)
+	if restore["ip-address"] != "10.0.0.10" || restore["subnet-id"] != float64(1) || restore["hostname"] != "web" {
+		t.Errorf("restored reservation = %v", restore)
+	}
+	options, _ := restore["option-data"].([]any)
+	if len(options) != 1 || options[0].(map[string]any)["always-send"] != true {
+		t.Errorf("restored option-data = %v", restore["option-data"])
+	}
+	if res := *reservations; len(res) != 1 || res[0]["ip-address"] != "10.0.0.10" {
+		t.Errorf("reservations after restore = %v", res)
+	}
+}
+
+func TestKeaCtrlUpdateHostRestoreFails(t *testing.T) {
+	reservationStub(t, false, func(map[string]any) bool { return true })
+
+	address := "10.0.0.20"
+	_, _, err := keaCtrlBackend{}.UpdateHost("web", models.HostUpdate{FixedAddress: &address}, Precondition{})
+	var restoreErr *KeaRestoreError
+	if !errors.As(err, &restoreErr) || restoreErr.Host != "web" {
+		t.Fatalf("UpdateHost error = %v, want a KeaRestoreError", err)
+	}
+	if !strings.Contains(err.Error(), "reservation web was deleted and could not be restored") {
+		t.Errorf("error = %q", err)
+	}
+}
+
+func TestKeaCtrlAddHostDoesNotReload(t *testing.T) {
+	stub := &keaStub{answer: func(command string, args map[string]any) map[string]any {
+		switch command {
+		case "config-get":
+			return map[string]any{"result": 0, "arguments": map[string]any{
+				"Dhcp4": map[string]any{"subnet4": []any{map[string]any{"id": 1, "subnet": "10.0.0.0/24"}}},
+			}}
+		case "reservation-get-all":
+			return map[string]any{"result": 3, "text": "0 IPv4 host(s) found."}
+		case "reservation-add", "config-reload":
+			return map[string]any{"result": 0}
+		}
+		return map[string]any{"result": 2, "text": "'" + command + "' command not supported."}
+	}}
+	serveKeaSocket(t, stub)
+	config.AppConfig.ApplyMode = "immediate"
+
+	b := keaCtrlBackend{}
+	if _, err := b.AddHost(models.Host{Name: "web", HardwareEthernet: "aa:bb:cc:00:00:10", FixedAddress: "10.0.0.10"}); err != nil {
+		t.Fatalf("AddHost: %v", err)
+	}
+	if result := b.HostsChanged(); !result.Success || result.Command != "" {
+		t.Errorf("HostsChanged = %+v, want success without a command", result)
+	}
+	for _, command := range stub.commands() {
+		if command == "config-reload" {
+			t.Errorf("commands = %v, want no config-reload", stub.commands())
+		}
+	}
+
+	// Interface edits still reload
+	if result := b.NotifyChanged(); !result.Success || result.Command != "config-reload" {
+		t.Errorf("NotifyChanged = %+v, want config-reload", result)
+	}
+}
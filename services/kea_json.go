@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// keyOrder records the key order of the JSON objects of a decoded Kea
+// configuration, so the file can be written back with its keys where the
+// administrator put them. Objects are identified by their map.
+type keyOrder map[uintptr][]string
+
+func mapID(m map[string]any) uintptr {
+	return reflect.ValueOf(m).Pointer()
+}
+
+// decodeKeaJSON decodes a Kea configuration object, keeping numbers as
+// json.Number and recording the key order of every object
+func decodeKeaJSON(data []byte) (map[string]any, keyOrder, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	order := keyOrder{}
+	v, err := order.decode(dec)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, ok := v.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("expected a JSON object")
+	}
+	return root, order, nil
+}
+
+func (o keyOrder) decode(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil // string, json.Number, bool or nil
+	}
+	switch delim {
+	case '{':
+		m := map[string]any{}
+		var keys []string
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			v, err := o.decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := m[key]; !dup {
+				keys = append(keys, key)
+			}
+			m[key] = v
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		o[mapID(m)] = keys
+		return m, nil
+	case '[':
+		list := []any{}
+		for dec.More() {
+			v, err := o.decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+// inherit gives m and the objects nested in it the key order of old, for
+// an object rebuilt from it. List entries are paired by their "name" key
+// if they have one, otherwise by position.
+func (o keyOrder) inherit(m, old map[string]any) {
+	if m == nil || old == nil {
+		return
+	}
+	if keys, ok := o[mapID(old)]; ok {
+		o[mapID(m)] = keys
+	}
+	for k, v := range m {
+		switch v := v.(type) {
+		case map[string]any:
+			oldMap, _ := old[k].(map[string]any)
+			o.inherit(v, oldMap)
+		case []any:
+			oldList, _ := old[k].([]any)
+			for i, item := range v {
+				itemMap, _ := item.(map[string]any)
+				o.inherit(itemMap, matchingEntry(oldList, itemMap, i))
+			}
+		}
+	}
+}
+
+// matchingEntry returns the object in list with the same "name" as m, or
+// the one at index i if m has no name
+func matchingEntry(list []any, m map[string]any, i int) map[string]any {
+	if name, ok := m["name"].(string); ok {
+		for _, item := range list {
+			if other, _ := item.(map[string]any); other != nil && other["name"] == name {
+				return other
+			}
+		}
+		return nil
+	}
+	if i < len(list) {
+		other, _ := list[i].(map[string]any)
+		return other
+	}
+	return nil
+}
+
+// keys returns the keys of m in their recorded order, followed by any
+// added since in sorted order
+func (o keyOrder) keys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for _, k := range o[mapID(m)] {
+		if _, ok := m[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	var added []string
+	for k := range m {
+		if !slices.Contains(keys, k) {
+			added = append(added, k)
+		}
+	}
+	slices.Sort(added)
+	return append(keys, added...)
+}
+
+// encode writes v as JSON indented by four spaces, like json.Encoder with
+// SetIndent, but with object keys in their recorded order
+func (o keyOrder) encode(buf *bytes.Buffer, v any, indent string) error {
+	const unit = "    "
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		keys := o.keys(v)
+		for i, k := range keys {
+			buf.WriteString(indent + unit)
+			if err := encodeJSONValue(buf, k, ""); err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			if err := o.encode(buf, v[k], indent+unit); err != nil {
+				return err
+			}
+			if i < len(keys)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, item := range v {
+			buf.WriteString(indent + unit)
+			if err := o.encode(buf, item, indent+unit); err != nil {
+				return err
+			}
+			if i < len(v)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+	default:
+		return encodeJSONValue(buf, v, indent)
+	}
+	return nil
+}
+
+// encodeJSONValue writes any other value with encoding/json, without HTML
+// escaping and indented to fit at indent
+func encodeJSONValue(buf *bytes.Buffer, v any, indent string) error {
+	var tmp bytes.Buffer
+	enc := json.NewEncoder(&tmp)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(indent, "    ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(tmp.Bytes(), []byte("\n")))
+	return nil
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// ListLeases reads dhcpd.leases and returns the current lease of every
+// address matching filter, ordered by address
+func ListLeases(filter models.LeaseFilter) ([]models.Lease, error) {
+	leases, err := readLeases()
+	if err != nil {
+		return nil, err
+	}
+	return filterLeases(leases, filter)
+}
+
+func filterLeases(leases []models.Lease, filter models.LeaseFilter) ([]models.Lease, error) {
+	var subnet *net.IPNet
+	if filter.Subnet != "" {
+		_, n, err := net.ParseCIDR(filter.Subnet)
+		if err != nil {
+			return nil, &FieldError{"subnet", fmt.Sprintf("invalid subnet filter %q: expected CIDR notation", filter.Subnet)}
+		}
+		subnet = n
+	}
+	mac := filter.MAC
+	if mac != "" {
+		normalized, err := normalizeMAC("mac", mac)
+		if err != nil {
+			return nil, err
+		}
+		mac = normalized
+	}
+
+	matched := []models.Lease{}
+	for _, lease := range leases {
+		if mac != "" && !sameMAC(lease.HardwareEthernet, mac) {
+			continue
+		}
+		if filter.IP != "" && lease.IP != filter.IP {
+			continue
+		}
+		if filter.State != "" && lease.BindingState != filter.State {
+			continue
+		}
+		if filter.Hostname != "" && !strings.EqualFold(lease.ClientHostname, filter.Hostname) {
+			continue
+		}
+		if subnet != nil && !subnet.Contains(net.ParseIP(lease.IP)) {
+			continue
+		}
+		matched = append(matched, lease)
+	}
+	return matched, nil
+}
+
+// sameMAC compares a lease MAC with a normalized one, accepting any of the
+// notations normalizeMAC does
+func sameMAC(leaseMAC, mac string) bool {
+	if normalized, err := normalizeMAC("", leaseMAC); err == nil {
+		return normalized == mac
+	}
+	return strings.EqualFold(leaseMAC, mac)
+}
+
+// readLeases parses the lease database. dhcpd only ever appends to it, so
+// the last entry for an address is the current one.
+func readLeases() ([]models.Lease, error) {
+	content, err := os.ReadFile(config.AppConfig.LeasesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Leases file not found at %s, returning no leases", config.AppConfig.LeasesPath)
+			return nil, nil
+		}
+		log.Printf("Error reading leases file: %v", err)
+		return nil, fmt.Errorf("failed to read leases file: %w", err)
+	}
+
+	f, err := parseLeases(content)
+	if err != nil {
+		log.Printf("Error parsing leases file: %v", err)
+		return nil, fmt.Errorf("failed to parse leases file: %w", err)
+	}
+
+	latest := make(map[string]models.Lease)
+	for _, b := range f.Blocks("lease") {
+		if len(b.Args) != 2 {
+			continue
+		}
+		latest[b.Args[1]] = leaseFromBlock(b)
+	}
+	return leasesByAddress(latest), nil
+}
+
+// parseLeases parses dhcpd.leases. dhcpd may be halfway through appending
+// a lease when we read the file, so if the file does not parse, the text
+// after the last top-level closing brace is dropped and parsing retried.
+func parseLeases(content []byte) (*dhcpconf.File, error) {
+	f, err := dhcpconf.Parse(content)
+	if err == nil {
+		return f, nil
+	}
+	end := bytes.LastIndex(content, []byte("\n}"))
+	if end < 0 {
+		return nil, err
+	}
+	if complete, retryErr := dhcpconf.Parse(content[:end+2]); retryErr == nil {
+		log.Printf("Skipping incomplete lease at the end of %s: %v", config.AppConfig.LeasesPath, err)
+		return complete, nil
+	}
+	return nil, err
+}
+
+// leasesByAddress returns the leases of a map keyed by address, ordered
+// numerically by address
+func leasesByAddress(latest map[string]models.Lease) []models.Lease {
+	leases := make([]models.Lease, 0, len(latest))
+	for _, lease := range latest {
+		leases = append(leases, lease)
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		a, errA := ipv4ToUint(leases[i].IP)
+		b, errB := ipv4ToUint(leases[j].IP)
+		if errA != nil || errB != nil {
+			return leases[i].IP < leases[j].IP
+		}
+		return a < b
+	})
+	return leases
+}
+
+func leaseFromBlock(b *dhcpconf.Block) models.Lease {
+	lease := models.Lease{
+		IP:               b.Args[1],
+		BindingState:     getParam(b, "binding", "state"),
+		HardwareEthernet: getParam(b, "hardware", "ethernet"),
+		ClientHostname:   dhcpconf.Unquote(getParam(b, "client-hostname")),
+		UID:              formatUID(getParam(b, "uid")),
+	}
+	if s := b.Find("starts"); s != nil {
+		lease.Starts = parseLeaseTime(s.Args[1:])
+	}
+	if s := b.Find("ends"); s != nil {
+		lease.Ends = parseLeaseTime(s.Args[1:])
+	}
+	return lease
+}
+
+// parseLeaseTime understands both time formats dhcpd writes:
+// `<weekday> yyyy/mm/dd hh:mm:ss` in UTC and `epoch <seconds>`. It returns
+// nil for `never` and for anything it cannot read.
+func parseLeaseTime(args []string) *time.Time {
+	switch {
+	case len(args) == 2 && args[0] == "epoch":
+		secs, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil
+		}
+		t := time.Unix(secs, 0).UTC()
+		return &t
+	case len(args) == 3:
+		t, err := time.Parse("2006/01/02 15:04:05", args[1]+" "+args[2])
+		if err != nil {
+			return nil
+		}
+		return &t
+	}
+	return nil
+}
+
+// formatUID renders a client identifier as colon-separated hex octets.
+// dhcpd writes it either as a quoted string with octal escapes or already
+// in hex form.
+func formatUID(uid string) string {
+	if !strings.HasPrefix(uid, `"`) {
+		return uid
+	}
+	raw := dhcpconf.Unquote(uid)
+	octets := make([]string, len(raw))
+	for i := 0; i < len(raw); i++ {
+		octets[i] = fmt.Sprintf("%02x", raw[i])
+	}
+	return strings.Join(octets, ":")
+}
+
+// ReserveLease turns the current lease of ip into a fixed host reservation.
+// The MAC and name come from the lease; routers, netmask and DNS servers
+// come from the subnet the address belongs to.
+func ReserveLease(ip string, req models.LeaseReservation) (models.Host, error) {
+	leases, err := readLeases()
+	if err != nil {
+		return models.Host{}, err
+	}
+
+	host, err := leaseHost(leases, ip, req)
+	if err != nil {
+		return models.Host{}, err
+	}
+
+	err = editDhcpConf(func(f *dhcpconf.File) error {
+		if err := fillFromSubnet(f, &host); err != nil {
+			return err
+		}
+		return addHost(f, &host)
+	})
+	return host, err
+}
+
+// fillFromSubnet completes the routers, netmask and DNS servers of a host
+// from the subnet containing its fixed address, falling back to the global
+// DNS servers
+func fillFromSubnet(f *dhcpconf.File, host *models.Host) error {
+	addr, err := ipv4ToUint(host.FixedAddress)
+	if err != nil {
+		return err
+	}
+
+	var subnet *dhcpconf.Block
+	for _, b := range subnetBlocks(f) {
+		// Catch-all 0.0.0.0/0 declarations carry no useful defaults
+		if b.Args[3] == "0.0.0.0" {
+			continue
+		}
+		first, last, err := subnetBounds(b)
+		if err != nil || addr < first || addr > last {
+			continue
+		}
+		// Prefer the most specific subnet
+		if subnet == nil || last-first < subnetSize(subnet) {
+			subnet = b
+		}
+	}
+	if subnet == nil {
+		return fmt.Errorf("no subnet declared for %s", host.FixedAddress)
+	}
+
+	if host.OptionRouters == "" {
+		host.OptionRouters = getParam(subnet, "option", "routers")
+	}
+	if host.OptionSubnetMask == "" {
+		host.OptionSubnetMask = getParam(subnet, "option", "subnet-mask")
+	}
+	if host.OptionSubnetMask == "" {
+		host.OptionSubnetMask = subnet.Args[3]
+	}
+	if host.OptionDomainNameServers == "" {
+		host.OptionDomainNameServers = getParam(subnet, "option", "domain-name-servers")
+	}
+	if host.OptionDomainNameServers == "" {
+		host.OptionDomainNameServers = getParam(globalScope{f}, "option", "domain-name-servers")
+	}
+	return nil
+}
+
+func subnetSize(b *dhcpconf.Block) uint32 {
+	first, last, _ := subnetBounds(b)
+	return last - first
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+// ipv4ToUint parses an IPv4 address into an integer for range arithmetic
+func ipv4ToUint(s string) (uint32, error) {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() == nil {
+		return 0, fmt.Errorf("invalid IPv4 address: %s", s)
+	}
+	return binary.BigEndian.Uint32(ip.To4()), nil
+}
+
+func uintToIPv4(n uint32) string {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip.String()
+}
+
+// subnetBounds returns the first and last address covered by a subnet block
+func subnetBounds(b *dhcpconf.Block) (uint32, uint32, error) {
+	network, err := ipv4ToUint(b.Args[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	mask, err := ipv4ToUint(b.Args[3])
+	if err != nil {
+		return 0, 0, err
+	}
+	return network & mask, network | ^mask, nil
+}
+
+func rangeFromStatement(s *dhcpconf.Statement) (models.Range, bool) {
+	args, ok := s.Match("range")
+	if !ok {
+		return models.Range{}, false
+	}
+	var r models.Range
+	if len(args) > 0 && args[0] == "dynamic-bootp" {
+		r.DynamicBootp = true
+		args = args[1:]
+	}
+	switch len(args) {
+	case 1:
+		r.Start = args[0]
+	case 2:
+		r.Start, r.End = args[0], args[1]
+	default:
+		return models.Range{}, false
+	}
+	return r, true
+}
+
+func rangeStatement(r models.Range) *dhcpconf.Statement {
+	args := []string{"range"}
+	if r.DynamicBootp {
+		args = append(args, "dynamic-bootp")
+	}
+	args = append(args, r.Start)
+	if r.End != "" {
+		args = append(args, r.End)
+	}
+	return dhcpconf.NewStatement(args...)
+}
+
+// rangeBounds returns the numeric start and end of a range
+func rangeBounds(r models.Range) (uint32, uint32, error) {
+	start, err := ipv4ToUint(r.Start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range start: %w", err)
+	}
+	end := start
+	if r.End != "" {
+		if end, err = ipv4ToUint(r.End); err != nil {
+			return 0, 0, fmt.Errorf("invalid range end: %w", err)
+		}
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range %s-%s ends before it starts", r.Start, r.End)
+	}
+	return start, end, nil
+}
+
+// rangeStatements returns the range statements of a subnet, including the
+// ones inside its pools
+func rangeStatements(subnet *dhcpconf.Block) []*dhcpconf.Statement {
+	var ranges []*dhcpconf.Statement
+	dhcpconf.Walk(subnet.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+		switch n := n.(type) {
+		case *dhcpconf.Statement:
+			if _, ok := rangeFromStatement(n); ok {
+				ranges = append(ranges, n)
+			}
+		case *dhcpconf.Block:
+			return n.Keyword() == "pool"
+		}
+		return false
+	})
+	return ranges
+}
+
+// directRanges returns the range statements that sit directly in block
+func directRanges(b *dhcpconf.Block) []*dhcpconf.Statement {
+	var ranges []*dhcpconf.Statement
+	for _, n := range b.Body {
+		if s, ok := n.(*dhcpconf.Statement); ok {
+			if _, ok := rangeFromStatement(s); ok {
+				ranges = append(ranges, s)
+			}
+		}
+	}
+	return ranges
+}
+
+// validateRanges checks that candidates lie inside the subnet and overlap
+// neither each other nor any existing range other than those in replaced
+func validateRanges(subnet *dhcpconf.Block, candidates []models.Range, replaced []*dhcpconf.Statement) error {
+	first, last, err := subnetBounds(subnet)
+	if err != nil {
+		return err
+	}
+
+	type span struct {
+		start, end uint32
+		r          models.Range
+	}
+	var existing []span
+	for _, s := range rangeStatements(subnet) {
+		if slices.Contains(replaced, s) {
+			continue
+		}
+		r, _ := rangeFromStatement(s)
+		start, end, err := rangeBounds(r)
+		if err != nil {
+			continue
+		}
+		existing = append(existing, span{start, end, r})
+	}
+
+	for _, r := range candidates {
+		start, end, err := rangeBounds(r)
+		if err != nil {
+			return err
+		}
+		if start < first || end > last {
+			return fmt.Errorf("range %s is outside subnet %s netmask %s", formatRange(r), subnet.Args[1], subnet.Args[3])
+		}
+		for _, other := range existing {
+			if start <= other.end && other.start <= end {
+				return fmt.Errorf("range %s overlaps existing range %s", formatRange(r), formatRange(other.r))
+			}
+		}
+		existing = append(existing, span{start, end, r})
+	}
+	return nil
+}
+
+func formatRange(r models.Range) string {
+	if r.End == "" {
+		return r.Start
+	}
+	return r.Start + "-" + r.End
+}
+
+func ListRanges(network string) ([]models.Range, error) {
+	f, err := loadDhcpConf()
+	if err != nil {
+		return nil, err
+	}
+	subnet := findSubnet(f, network)
+	if subnet == nil {
+		return nil, fmt.Errorf("subnet %s %w", network, ErrNotFound)
+	}
+	return rangesOf(subnet), nil
+}
+
+func rangesOf(b *dhcpconf.Block) []models.Range {
+	ranges := []models.Range{}
+	for _, s := range directRanges(b) {
+		r, _ := rangeFromStatement(s)
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// findRange returns the range statement directly in subnet that starts at start
+func findRange(subnet *dhcpconf.Block, start string) *dhcpconf.Statement {
+	for _, s := range directRanges(subnet) {
+		if r, _ := rangeFromStatement(s); r.Start == start {
+			return s
+		}
+	}
+	return nil
+}
+
+func AddRange(network string, r models.Range) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		subnet := findSubnet(f, network)
+		if subnet == nil {
+			return fmt.Errorf("subnet %s %w", network, ErrNotFound)
+		}
+		return addRanges(subnet, []models.Range{r})
+	})
+}
+
+func addRanges(subnet *dhcpconf.Block, ranges []models.Range) error {
+	if err := validateRanges(subnet, ranges, nil); err != nil {
+		return err
+	}
+	var last dhcpconf.Node
+	if existing := directRanges(subnet); len(existing) > 0 {
+		last = existing[len(existing)-1]
+	}
+	for _, r := range ranges {
+		s := rangeStatement(r)
+		subnet.InsertAfter(last, s)
+		last = s
+	}
+	return nil
+}
+
+// UpdateRange resizes the range starting at start
+func UpdateRange(network, start string, r models.Range) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		subnet := findSubnet(f, network)
+		if subnet == nil {
+			return fmt.Errorf("subnet %s %w", network, ErrNotFound)
+		}
+		existing := findRange(subnet, start)
+		if existing == nil {
+			log.Printf("Range %s not found in subnet %s for update.", start, network)
+			return fmt.Errorf("range %s in subnet %s %w", start, network, ErrNotFound)
+		}
+		if err := validateRanges(subnet, []models.Range{r}, []*dhcpconf.Statement{existing}); err != nil {
+			return err
+		}
+		existing.Args = rangeStatement(r).Args
+		return nil
+	})
+}
+
+// DeleteRange removes a range and reports whether it was there
+func DeleteRange(network, start string) (bool, error) {
+	deleted := false
+	err := editDhcpConf(func(f *dhcpconf.File) error {
+		subnet := findSubnet(f, network)
+		if subnet == nil {
+			return fmt.Errorf("subnet %s %w", network, ErrNotFound)
+		}
+		existing := findRange(subnet, start)
+		if existing == nil {
+			log.Printf("Range %s not found in subnet %s for deletion.", start, network)
+			return errUnchanged // idempotent delete
+		}
+		subnet.Remove(existing)
+		deleted = true
+		return nil
+	})
+	return deleted && err == nil, err
+}
+
+func poolFromBlock(b *dhcpconf.Block) models.Pool {
+	pool := models.Pool{Ranges: rangesOf(b)}
+	for _, n := range b.Body {
+		s, ok := n.(*dhcpconf.Statement)
+		if !ok {
+			continue
+		}
+		if args, ok := s.Match("allow"); ok {
+			pool.Allow = append(pool.Allow, dhcpconf.Join(args))
+		}
+		if args, ok := s.Match("deny"); ok {
+			pool.Deny = append(pool.Deny, dhcpconf.Join(args))
+		}
+		if args, ok := s.Match("failover", "peer"); ok {
+			pool.FailoverPeer = dhcpconf.Unquote(dhcpconf.Join(args))
+		}
+	}
+	return pool
+}
+
+func ListPools(network string) ([]models.Pool, error) {
+	f, err := loadDhcpConf()
+	if err != nil {
+		return nil, err
+	}
+	subnet := findSubnet(f, network)
+	if subnet == nil {
+		return nil, fmt.Errorf("subnet %s %w", network, ErrNotFound)
+	}
+	return poolsOf(subnet), nil
+}
+
+func poolsOf(subnet *dhcpconf.Block) []models.Pool {
+	pools := []models.Pool{}
+	for _, b := range subnet.Blocks("pool") {
+		pools = append(pools, poolFromBlock(b))
+	}
+	return pools
+}
+
+// findPool returns the index-th pool block of a subnet, counting from zero
+func findPool(subnet *dhcpconf.Block, index int) *dhcpconf.Block {
+	pools := subnet.Blocks("pool")
+	if index < 0 || index >= len(pools) {
+		return nil
+	}
+	return pools[index]
+}
+
+func AddPool(network string, pool models.Pool) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		subnet := findSubnet(f, network)
+		if subnet == nil {
+			return fmt.Errorf("subnet %s %w", network, ErrNotFound)
+		}
+		return addPool(subnet, pool)
+	})
+}
+
+func addPool(subnet *dhcpconf.Block, pool models.Pool) error {
+	b := dhcpconf.NewBlock("pool")
+	if err := applyPoolUpdate(subnet, b, models.PoolUpdate{
+		FailoverPeer: &pool.FailoverPeer,
+		Ranges:       &pool.Ranges,
+		Allow:        &pool.Allow,
+		Deny:         &pool.Deny,
+	}); err != nil {
+		return err
+	}
+	subnet.Append(b)
+	return nil
+}
+
+func UpdatePool(network string, index int, updates models.PoolUpdate) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		subnet := findSubnet(f, network)
+		if subnet == nil {
+			return fmt.Errorf("subnet %s %w", network, ErrNotFound)
+		}
+		pool := findPool(subnet, index)
+		if pool == nil {
+			log.Printf("Pool %d not found in subnet %s for update.", index, network)
+			return fmt.Errorf("pool %d in subnet %s %w", index, network, ErrNotFound)
+		}
+		return applyPoolUpdate(subnet, pool, updates)
+	})
+}
+
+func applyPoolUpdate(subnet, pool *dhcpconf.Block, updates models.PoolUpdate) error {
+	if updates.FailoverPeer != nil {
+		peer := ""
+		if *updates.FailoverPeer != "" {
+			peer = dhcpconf.Quote(*updates.FailoverPeer)
+		}
+		if err := setParam(pool, []string{"failover", "peer"}, peer); err != nil {
+			return err
+		}
+	}
+	if updates.Ranges != nil {
+		old := directRanges(pool)
+		if err := validateRanges(subnet, *updates.Ranges, old); err != nil {
+			return err
+		}
+		for _, s := range old {
+			pool.Remove(s)
+		}
+		for _, r := range *updates.Ranges {
+			pool.Append(rangeStatement(r))
+		}
+	}
+	if updates.Allow != nil {
+		if err := setPermits(pool, "allow", *updates.Allow); err != nil {
+			return err
+		}
+	}
+	if updates.Deny != nil {
+		if err := setPermits(pool, "deny", *updates.Deny); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPermits replaces every allow or deny statement of a pool
+func setPermits(pool *dhcpconf.Block, keyword string, permits []string) error {
+	var statements []dhcpconf.Node
+	for _, permit := range permits {
+		args, err := dhcpconf.Fields(permit)
+		if err != nil || len(args) == 0 {
+			return fmt.Errorf("invalid %s rule %q", keyword, permit)
+		}
+		statements = append(statements, dhcpconf.NewStatement(append([]string{keyword}, args...)...))
+	}
+	for s := pool.Find(keyword); s != nil; s = pool.Find(keyword) {
+		pool.Remove(s)
+	}
+	pool.Append(statements...)
+	return nil
+}
+
+// DeletePool removes the pool at index. Pools have no name to make the
+// delete idempotent on, so an index past the end is reported as not found.
+func DeletePool(network string, index int) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		subnet := findSubnet(f, network)
+		if subnet == nil {
+			return fmt.Errorf("subnet %s %w", network, ErrNotFound)
+		}
+		pool := findPool(subnet, index)
+		if pool == nil {
+			log.Printf("Pool %d not found in subnet %s for deletion.", index, network)
+			return fmt.Errorf("pool %d %w", index, ErrNotFound)
+		}
+		subnet.Remove(pool)
+		return nil
+	})
+}
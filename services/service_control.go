@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+const applyTimeout = 60 * time.Second
+
+var (
+	applyMu       sync.Mutex // serializes runs of the apply command
+	debounceMu    sync.Mutex
+	debounceTimer *time.Timer
+)
+
+// ApplyChanges runs the configured restart/reload command right away
+func ApplyChanges() models.ApplyResult {
+	applyMu.Lock()
+	defer applyMu.Unlock()
+
+	command := config.AppConfig.ApplyCommand
+	result := models.ApplyResult{Mode: config.AppConfig.ApplyMode, Command: command}
+	if strings.TrimSpace(command) == "" {
+		result.Success = true
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), applyTimeout)
+	defer cancel()
+
+	// Run through the shell so the command can carry arguments, pipes or
+	// point at a stub script
+	output, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+	result.Output = strings.TrimSpace(string(output))
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode = -1
+		}
+		result.Error = err.Error()
+		log.Printf("Apply command %q failed: %v", command, err)
+		return result
+	}
+
+	result.Success = true
+	return result
+}
+
+// NotifyChanged is called after every successful configuration write and
+// applies the change according to APPLY_MODE
+func NotifyChanged() models.ApplyResult {
+	return notifyChanged(ApplyChanges)
+}
+
+// notifyChanged is NotifyChanged for a backend that applies changes with
+// apply instead of APPLY_COMMAND
+func notifyChanged(apply func() models.ApplyResult) models.ApplyResult {
+	switch config.AppConfig.ApplyMode {
+	case "manual":
+		return models.ApplyResult{Mode: "manual", Pending: true, Success: true}
+	case "debounce":
+		scheduleApply(config.AppConfig.ApplyDebounce, apply)
+		return models.ApplyResult{Mode: "debounce", Pending: true, Success: true}
+	default:
+		return apply()
+	}
+}
+
+// scheduleApply (re)starts the debounce timer so a burst of writes causes a
+// single restart once things have been quiet for delay
+func scheduleApply(delay time.Duration, apply func() models.ApplyResult) {
+	debounceMu.Lock()
+	defer debounceMu.Unlock()
+
+	if debounceTimer != nil {
+		debounceTimer.Stop()
+	}
+	debounceTimer = time.AfterFunc(delay, func() {
+		result := apply()
+		if result.Success {
+			log.Printf("Debounced apply completed: %s", result.Command)
+		} else {
+			log.Printf("Debounced apply failed with exit code %d: %s", result.ExitCode, result.Output)
+		}
+	})
+}
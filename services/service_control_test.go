@@ -0,0 +1,122 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/config"
+)
+
+// stubApplyCommand points APPLY_COMMAND at a script that appends a line to
+// a log for every run, prints output and exits with status. It returns a
+// function counting the runs so far.
+func stubApplyCommand(t *testing.T, mode, output string, status int) func() int {
+	t.Helper()
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	script := filepath.Join(dir, "apply.sh")
+	body := "#!/bin/sh\necho run >> " + runs + "\necho '" + output + "'\nexit " + strconv.Itoa(status) + "\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = saved })
+	config.AppConfig.ApplyCommand = script
+	config.AppConfig.ApplyMode = mode
+	config.AppConfig.ApplyDebounce = 50 * time.Millisecond
+
+	return func() int {
+		content, err := os.ReadFile(runs)
+		if os.IsNotExist(err) {
+			return 0
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(content), "run\n")
+	}
+}
+
+func TestNotifyChangedImmediate(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		status  int
+		success bool
+	}{
+		{"success", "reloaded", 0, true},
+		{"failure", "unit not found", 5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := stubApplyCommand(t, "immediate", tt.output, tt.status)
+
+			result := NotifyChanged()
+			if result.Mode != "immediate" || result.Pending {
+				t.Errorf("mode = %q, pending = %v", result.Mode, result.Pending)
+			}
+			if result.Success != tt.success || result.ExitCode != tt.status {
+				t.Errorf("success = %v, exit code = %d; want %v, %d", result.Success, result.ExitCode, tt.success, tt.status)
+			}
+			if result.Output != tt.output {
+				t.Errorf("output = %q, want %q", result.Output, tt.output)
+			}
+			if tt.success != (result.Error == "") {
+				t.Errorf("error = %q", result.Error)
+			}
+			if n := runs(); n != 1 {
+				t.Errorf("apply ran %d times, want 1", n)
+			}
+		})
+	}
+}
+
+func TestNotifyChangedDebounce(t *testing.T) {
+	runs := stubApplyCommand(t, "debounce", "ok", 0)
+
+	for i := 0; i < 5; i++ {
+		result := NotifyChanged()
+		if result.Mode != "debounce" || !result.Pending || !result.Success {
+			t.Fatalf("write %d: got %+v", i, result)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runs(); n != 0 {
+		t.Fatalf("apply ran %d times during the burst, want 0", n)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runs() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if n := runs(); n != 1 {
+		t.Errorf("apply ran %d times after the burst, want 1", n)
+	}
+}
+
+func TestNotifyChangedManual(t *testing.T) {
+	runs := stubApplyCommand(t, "manual", "ok", 0)
+
+	result := NotifyChanged()
+	if result.Mode != "manual" || !result.Pending || !result.Success {
+		t.Errorf("got %+v", result)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := runs(); n != 0 {
+		t.Fatalf("apply ran %d times in manual mode, want 0", n)
+	}
+
+	// POST /service/apply still runs the command on demand
+	if result := ApplyChanges(); !result.Success || result.Output != "ok" {
+		t.Errorf("ApplyChanges = %+v", result)
+	}
+	if n := runs(); n != 1 {
+		t.Errorf("apply ran %d times, want 1", n)
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/0xPixelNinja/dhcp-rest-api/dhcpconf"
+	"github.com/0xPixelNinja/dhcp-rest-api/models"
+)
+
+func ListSubnets() ([]models.Subnet, error) {
+	f, err := loadDhcpConf()
+	if err != nil {
+		return nil, err
+	}
+
+	var subnets []models.Subnet
+	for _, b := range subnetBlocks(f) {
+		subnet := subnetFromBlock(b)
+		for _, shared := range f.Blocks("shared-network") {
+			if slices.Contains(shared.Body, dhcpconf.Node(b)) {
+				subnet.SharedNetwork = shared.Name()
+			}
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+// subnetBlocks returns every subnet declaration, including those nested in
+// shared-network blocks
+func subnetBlocks(f *dhcpconf.File) []*dhcpconf.Block {
+	var subnets []*dhcpconf.Block
+	dhcpconf.Walk(f.Body, func(n dhcpconf.Node, parents []*dhcpconf.Block) bool {
+		b, ok := n.(*dhcpconf.Block)
+		if !ok {
+			return false
+		}
+		if b.Keyword() == "subnet" && len(b.Args) == 4 {
+			subnets = append(subnets, b)
+			return false
+		}
+		return b.Keyword() != "host"
+	})
+	return subnets
+}
+
+func findSubnet(f *dhcpconf.File, network string) *dhcpconf.Block {
+	for _, b := range subnetBlocks(f) {
+		if b.Args[1] == network {
+			return b
+		}
+	}
+	return nil
+}
+
+func subnetFromBlock(b *dhcpconf.Block) models.Subnet {
+	return models.Subnet{
+		Network:                 b.Args[1],
+		Netmask:                 b.Args[3],
+		OptionRouters:           getParam(b, "option", "routers"),
+		OptionDomainNameServers: getParam(b, "option", "domain-name-servers"),
+		OptionDomainName:        dhcpconf.Unquote(getParam(b, "option", "domain-name")),
+		DefaultLeaseTime:        getIntParam(b, "default-lease-time"),
+		MaxLeaseTime:            getIntParam(b, "max-lease-time"),
+		Authoritative:           b.Find("authoritative") != nil,
+		DenyUnknownClients:      b.Find("deny", "unknown-clients") != nil || b.Find("deny-unknown-clients") != nil,
+		Ranges:                  rangesOf(b),
+		Pools:                   poolsOf(b),
+	}
+}
+
+// validateSubnetAddress checks that netmask is contiguous and that network
+// is the first address of the subnet it describes
+func validateSubnetAddress(network, netmask string) error {
+	ip := net.ParseIP(network)
+	if ip == nil || ip.To4() == nil || strings.Contains(network, ":") {
+		return &FieldError{"network", fmt.Sprintf("invalid subnet network address %q", network)}
+	}
+	if err := validateNetmask("netmask", netmask); err != nil {
+		return err
+	}
+	mask := net.IPMask(net.ParseIP(netmask).To4())
+	if !ip.To4().Mask(mask).Equal(ip) {
+		return &FieldError{"network", fmt.Sprintf("%s is not the network address for netmask %s", network, netmask)}
+	}
+	return nil
+}
+
+func AddSubnet(subnet models.Subnet) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		return addSubnet(f, subnet)
+	})
+}
+
+func addSubnet(f *dhcpconf.File, subnet models.Subnet) error {
+	if err := validateSubnetAddress(subnet.Network, subnet.Netmask); err != nil {
+		return err
+	}
+	if findSubnet(f, subnet.Network) != nil {
+		return fmt.Errorf("subnet %s already exists", subnet.Network)
+	}
+
+	b := dhcpconf.NewBlock("subnet", subnet.Network, "netmask", subnet.Netmask)
+	if err := applySubnetUpdate(b, models.SubnetUpdate{
+		OptionRouters:           &subnet.OptionRouters,
+		OptionDomainNameServers: &subnet.OptionDomainNameServers,
+		OptionDomainName:        &subnet.OptionDomainName,
+		DefaultLeaseTime:        &subnet.DefaultLeaseTime,
+		MaxLeaseTime:            &subnet.MaxLeaseTime,
+		Authoritative:           &subnet.Authoritative,
+		DenyUnknownClients:      &subnet.DenyUnknownClients,
+	}); err != nil {
+		return err
+	}
+	if err := addRanges(b, subnet.Ranges); err != nil {
+		return err
+	}
+	for _, pool := range subnet.Pools {
+		if err := addPool(b, pool); err != nil {
+			return err
+		}
+	}
+
+	if subnet.SharedNetwork != "" {
+		shared := findSharedNetwork(f, subnet.SharedNetwork)
+		if shared == nil {
+			return &FieldError{"shared_network", fmt.Sprintf("shared-network %s is not declared", subnet.SharedNetwork)}
+		}
+		shared.Append(b)
+		return nil
+	}
+	insertDeclaration(f, b)
+	return nil
+}
+
+func UpdateSubnet(network string, updates models.SubnetUpdate) error {
+	return editDhcpConf(func(f *dhcpconf.File) error {
+		b := findSubnet(f, network)
+		if b == nil {
+			log.Printf("Subnet %s not found for update.", network)
+			return fmt.Errorf("subnet %s %w", network, ErrNotFound)
+		}
+
+		newNetwork, newNetmask := b.Args[1], b.Args[3]
+		if updates.Network != nil && *updates.Network != "" {
+			newNetwork = *updates.Network
+		}
+		if updates.Netmask != nil && *updates.Netmask != "" {
+			newNetmask = *updates.Netmask
+		}
+		if err := validateSubnetAddress(newNetwork, newNetmask); err != nil {
+			return err
+		}
+		if newNetwork != network && findSubnet(f, newNetwork) != nil {
+			return fmt.Errorf("subnet %s already exists", newNetwork)
+		}
+		b.Args[1], b.Args[3] = newNetwork, newNetmask
+
+		// Re-check every range against the (possibly) new network
+		var ranges []models.Range
+		for _, s := range rangeStatements(b) {
+			r, _ := rangeFromStatement(s)
+			ranges = append(ranges, r)
+		}
+		if err := validateRanges(b, ranges, rangeStatements(b)); err != nil {
+			return err
+		}
+
+		return applySubnetUpdate(b, updates)
+	})
+}
+
+func applySubnetUpdate(b *dhcpconf.Block, updates models.SubnetUpdate) error {
+	if updates.OptionRouters != nil {
+		if err := setParam(b, []string{"option", "routers"}, *updates.OptionRouters); err != nil {
+			return err
+		}
+	}
+	if updates.OptionDomainNameServers != nil {
+		if err := setParam(b, []string{"option", "domain-name-servers"}, *updates.OptionDomainNameServers); err != nil {
+			return err
+		}
+	}
+	if updates.OptionDomainName != nil {
+		if err := setStringParam(b, []string{"option", "domain-name"}, *updates.OptionDomainName); err != nil {
+			return err
+		}
+	}
+	if updates.DefaultLeaseTime != nil {
+		if err := setIntParam(b, []string{"default-lease-time"}, *updates.DefaultLeaseTime); err != nil {
+			return err
+		}
+	}
+	if updates.MaxLeaseTime != nil {
+		if err := setIntParam(b, []string{"max-lease-time"}, *updates.MaxLeaseTime); err != nil {
+			return err
+		}
+	}
+	if updates.Authoritative != nil {
+		setFlag(b, *updates.Authoritative, "authoritative")
+	}
+	if updates.DenyUnknownClients != nil {
+		// Older configs use the hyphenated form; drop it in favour of the
+		// canonical `deny unknown-clients;`
+		if legacy := b.Find("deny-unknown-clients"); legacy != nil && !*updates.DenyUnknownClients {
+			b.Remove(legacy)
+		}
+		if b.Find("deny-unknown-clients") == nil {
+			setFlag(b, *updates.DenyUnknownClients, "deny", "unknown-clients")
+		}
+	}
+	return nil
+}
+
+// DeleteSubnet removes a subnet declaration. Like groups, subnets that
+// still hold hosts or groups must be emptied first.
+func DeleteSubnet(network string) (bool, error) {
+	deleted := false
+	err := editDhcpConf(func(f *dhcpconf.File) error {
+		b := findSubnet(f, network)
+		if b == nil {
+			log.Printf("Subnet %s not found for deletion.", network)
+			return errUnchanged // idempotent delete
+		}
+		hosts := 0
+		dhcpconf.Walk(b.Body, func(n dhcpconf.Node, _ []*dhcpconf.Block) bool {
+			if child, ok := n.(*dhcpconf.Block); ok && child.Keyword() == "host" {
+				hosts++
+				return false
+			}
+			return true
+		})
+		if hosts > 0 {
+			return fmt.Errorf("subnet %s still contains %d host(s)", network, hosts)
+		}
+		if groups := b.Blocks("group"); len(groups) > 0 {
+			return fmt.Errorf("subnet %s still contains %d group(s)", network, len(groups))
+		}
+		f.Remove(b)
+		deleted = true
+		return nil
+	})
+	return deleted && err == nil, err
+}